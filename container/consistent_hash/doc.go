@@ -0,0 +1,11 @@
+// Package consistent_hash maps keys to nodes with consistent hashing.
+//
+// Upgrading: Rings now place their entries differently from earlier
+// versions. The buckets of a node used to hash to a single point, and the
+// replica lists followed one global order of the nodes. Both are fixed,
+// so a Ring built from the same nodes now gives most keys a different
+// owner and different replicas. Processes that share a Ring (like cache
+// clients) must not mix versions. To upgrade without remapping the keys,
+// build the Ring with LegacyPlacement first and switch to the new
+// placement once every process runs the new version.
+package consistent_hash
@@ -0,0 +1,385 @@
+// Package hashtest checks the properties every consistent hashing algorithm
+// in consistent_hash (or any other implementation of the Locator contract)
+// is expected to have.
+package hashtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fd/go-util/container/consistent_hash"
+)
+
+// Build a Locator for nodes. A Builder may reject an empty list of nodes
+// by returning an error.
+type Builder func(nodes []consistent_hash.Node) (consistent_hash.Locator, error)
+
+type Config struct {
+	Build Builder
+
+	// The number of nodes to test with (defaults to 32)
+	Nodes int
+
+	// The number of keys to look up (defaults to 10000)
+	Keys int
+
+	// The maximum allowed ratio between the number of keys owned by
+	// the busiest node and the mean (defaults to 1.5)
+	Tolerance float64
+
+	// Make the i-th node (defaults to NodeID "node-000", "node-001", ...)
+	NewNode func(i int) consistent_hash.Node
+}
+
+// Run all checks as subtests of t.
+func Run(t *testing.T, c Config) {
+	t.Run("Determinism", func(t *testing.T) { CheckDeterminism(t, c) })
+	t.Run("Replicas", func(t *testing.T) { CheckReplicas(t, c) })
+	t.Run("Monotonicity", func(t *testing.T) { CheckMonotonicity(t, c) })
+	t.Run("Balance", func(t *testing.T) { CheckBalance(t, c) })
+	t.Run("ReplicaBalance", func(t *testing.T) { CheckReplicaBalance(t, c) })
+	t.Run("ReplicaStability", func(t *testing.T) { CheckReplicaStability(t, c) })
+	t.Run("Empty", func(t *testing.T) { CheckEmpty(t, c) })
+	t.Run("SingleNode", func(t *testing.T) { CheckSingleNode(t, c) })
+}
+
+// Lookups are stable across builds and don't depend on the order of
+// the nodes.
+func CheckDeterminism(t testing.TB, c Config) {
+	c = c.defaults()
+
+	var (
+		nodes    = c.nodes(c.Nodes)
+		reversed = make([]consistent_hash.Node, len(nodes))
+	)
+
+	for i, n := range nodes {
+		reversed[len(nodes)-1-i] = n
+	}
+
+	a, b := c.build(t, nodes), c.build(t, c.nodes(c.Nodes))
+	r := c.build(t, reversed)
+
+	for i := 0; i < c.Keys; i++ {
+		key := Key(i)
+
+		x, y, z := ids(lookup(a, key, -1)), ids(lookup(b, key, -1)), ids(lookup(r, key, -1))
+		if x != y {
+			t.Fatalf("%q: lookups differ between builds: %s != %s", key, x, y)
+		}
+		if x != z {
+			t.Fatalf("%q: lookups depend on the order of the nodes: %s != %s", key, x, z)
+		}
+	}
+}
+
+// Lookups return the requested number of distinct nodes.
+func CheckReplicas(t testing.TB, c Config) {
+	c = c.defaults()
+
+	l := c.build(t, c.nodes(c.Nodes))
+
+	for _, n := range []int{1, 3, c.Nodes, -1, c.Nodes + 5} {
+		expected := n
+		if n < 1 || n > c.Nodes {
+			expected = c.Nodes
+		}
+
+		for i := 0; i < c.Keys; i++ {
+			var (
+				key   = Key(i)
+				nodes = lookup(l, key, n)
+				seen  = make(map[string]bool, len(nodes))
+			)
+
+			if len(nodes) != expected {
+				t.Fatalf("%q: expected %d nodes, got %d", key, expected, len(nodes))
+			}
+
+			for _, node := range nodes {
+				if node == nil {
+					t.Fatalf("%q: lookup returned a nil node", key)
+				}
+				if seen[node.HashID()] {
+					t.Fatalf("%q: %s is returned more than once", key, node.HashID())
+				}
+				seen[node.HashID()] = true
+			}
+		}
+	}
+}
+
+// Adding a node only moves keys to the new node and removing a node only
+// moves the keys owned by the removed node.
+func CheckMonotonicity(t testing.TB, c Config) {
+	c = c.defaults()
+
+	var (
+		nodes   = c.nodes(c.Nodes + 1)
+		before  = c.build(t, nodes[:c.Nodes])
+		after   = c.build(t, nodes)
+		added   = nodes[c.Nodes].HashID()
+		removed = nodes[c.Nodes/2].HashID()
+		without = make([]consistent_hash.Node, 0, c.Nodes-1)
+		moved   int
+	)
+
+	for _, n := range nodes[:c.Nodes] {
+		if n.HashID() != removed {
+			without = append(without, n)
+		}
+	}
+
+	shrunk := c.build(t, without)
+
+	for i := 0; i < c.Keys; i++ {
+		key := Key(i)
+
+		x, y := owner(before, key), owner(after, key)
+		if x != y {
+			moved++
+			if y != added {
+				t.Fatalf("%q: moved from %s to %s after adding %s", key, x, y, added)
+			}
+		}
+
+		if z := owner(shrunk, key); x != removed && x != z {
+			t.Fatalf("%q: moved from %s to %s after removing %s", key, x, z, removed)
+		}
+	}
+
+	// the new node should take over about 1/(n+1) of the keys
+	if limit := 3 * c.Keys / (c.Nodes + 1); moved > limit {
+		t.Errorf("adding a node moved %d of %d keys (expected less than %d)", moved, c.Keys, limit)
+	}
+}
+
+// The keys are spread evenly over the nodes.
+func CheckBalance(t testing.TB, c Config) {
+	c = c.defaults()
+
+	var (
+		l      = c.build(t, c.nodes(c.Nodes))
+		counts = make(map[string]int, c.Nodes)
+		peak   int
+	)
+
+	for i := 0; i < c.Keys; i++ {
+		counts[owner(l, Key(i))]++
+	}
+
+	for _, n := range counts {
+		if n > peak {
+			peak = n
+		}
+	}
+
+	mean := float64(c.Keys) / float64(c.Nodes)
+	if ratio := float64(peak) / mean; ratio > c.Tolerance {
+		t.Errorf("peak-to-mean ratio %.3f exceeds %.3f", ratio, c.Tolerance)
+	}
+}
+
+// The first replicas of the keys are spread evenly over the nodes.
+func CheckReplicaBalance(t testing.TB, c Config) {
+	c = c.defaults()
+
+	var (
+		l      = c.build(t, c.nodes(c.Nodes))
+		n      = 3
+		counts = make([]map[string]int, n)
+	)
+
+	if n > c.Nodes {
+		n = c.Nodes
+	}
+
+	for pos := range counts {
+		counts[pos] = make(map[string]int, c.Nodes)
+	}
+
+	for i := 0; i < c.Keys; i++ {
+		for pos, node := range lookup(l, Key(i), n) {
+			counts[pos][node.HashID()]++
+		}
+	}
+
+	mean := float64(c.Keys) / float64(c.Nodes)
+	for pos := 1; pos < n; pos++ {
+		var peak int
+		for _, n := range counts[pos] {
+			if n > peak {
+				peak = n
+			}
+		}
+
+		if ratio := float64(peak) / mean; ratio > c.Tolerance {
+			t.Errorf("replica %d: peak-to-mean ratio %.3f exceeds %.3f", pos, ratio, c.Tolerance)
+		}
+	}
+}
+
+// Adding or removing a node leaves the order of the other nodes in the
+// replica lists unchanged. Keys owned by the added or removed node are
+// skipped as their replica lists may be rebuilt from a new owner.
+func CheckReplicaStability(t testing.TB, c Config) {
+	c = c.defaults()
+
+	var (
+		nodes   = c.nodes(c.Nodes + 1)
+		before  = c.build(t, nodes[:c.Nodes])
+		after   = c.build(t, nodes)
+		added   = nodes[c.Nodes].HashID()
+		removed = nodes[c.Nodes/2].HashID()
+		without = make([]consistent_hash.Node, 0, c.Nodes-1)
+	)
+
+	for _, n := range nodes[:c.Nodes] {
+		if n.HashID() != removed {
+			without = append(without, n)
+		}
+	}
+
+	shrunk := c.build(t, without)
+
+	for i := 0; i < c.Keys; i++ {
+		key := Key(i)
+
+		x, y := lookup(before, key, -1), lookup(after, key, -1)
+		if y[0].HashID() != added && ids(x) != ids(except(y, added)) {
+			t.Fatalf("%q: replicas changed from %s to %s after adding %s", key, ids(x), ids(y), added)
+		}
+
+		if x[0].HashID() == removed {
+			continue
+		}
+		if z := lookup(shrunk, key, -1); ids(except(x, removed)) != ids(z) {
+			t.Fatalf("%q: replicas changed from %s to %s after removing %s", key, ids(x), ids(z), removed)
+		}
+	}
+}
+
+// An empty list of nodes is either rejected or produces a Locator whose
+// lookups return no nodes.
+func CheckEmpty(t testing.TB, c Config) {
+	c = c.defaults()
+
+	l, err := c.Build(nil)
+	if err != nil {
+		return
+	}
+
+	for _, n := range []int{1, -1} {
+		nodes, err := safe_lookup(l, Key(0), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nodes) != 0 {
+			t.Fatalf("expected no nodes, got %d", len(nodes))
+		}
+	}
+}
+
+// With a single node every key is owned by that node.
+func CheckSingleNode(t testing.TB, c Config) {
+	c = c.defaults()
+
+	var (
+		nodes = c.nodes(1)
+		l     = c.build(t, nodes)
+	)
+
+	for i := 0; i < c.Keys; i++ {
+		key := Key(i)
+
+		found, err := safe_lookup(l, key, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 || found[0].HashID() != nodes[0].HashID() {
+			t.Fatalf("%q: expected [%s], got %s", key, nodes[0].HashID(), ids(found))
+		}
+	}
+}
+
+// The i-th key used by the checks
+func Key(i int) []byte {
+	return []byte(fmt.Sprintf("key-%d", i))
+}
+
+func (c Config) defaults() Config {
+	if c.Nodes < 2 {
+		c.Nodes = 32
+	}
+	if c.Keys <= 0 {
+		c.Keys = 10000
+	}
+	if c.Tolerance <= 0 {
+		c.Tolerance = 1.5
+	}
+	if c.NewNode == nil {
+		c.NewNode = func(i int) consistent_hash.Node {
+			return consistent_hash.NodeID(fmt.Sprintf("node-%03d", i))
+		}
+	}
+	return c
+}
+
+func (c Config) nodes(n int) []consistent_hash.Node {
+	o := make([]consistent_hash.Node, n)
+	for i := range o {
+		o[i] = c.NewNode(i)
+	}
+	return o
+}
+
+func (c Config) build(t testing.TB, nodes []consistent_hash.Node) consistent_hash.Locator {
+	l, err := c.Build(nodes)
+	if err != nil {
+		t.Fatalf("failed to build with %d nodes: %s", len(nodes), err)
+	}
+	return l
+}
+
+func lookup(l consistent_hash.Locator, key []byte, n int) []consistent_hash.Node {
+	return l.Lookup(key, l.MakeBuffer(n))
+}
+
+func safe_lookup(l consistent_hash.Locator, key []byte, n int) (nodes []consistent_hash.Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("lookup panicked: %v", r)
+		}
+	}()
+
+	return lookup(l, key, n), nil
+}
+
+func owner(l consistent_hash.Locator, key []byte) string {
+	nodes := lookup(l, key, 1)
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0].HashID()
+}
+
+func except(nodes []consistent_hash.Node, id string) []consistent_hash.Node {
+	o := make([]consistent_hash.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.HashID() != id {
+			o = append(o, n)
+		}
+	}
+	return o
+}
+
+func ids(nodes []consistent_hash.Node) string {
+	s := "["
+	for i, n := range nodes {
+		if i > 0 {
+			s += " "
+		}
+		s += n.HashID()
+	}
+	return s + "]"
+}
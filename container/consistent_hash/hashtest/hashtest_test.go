@@ -0,0 +1,24 @@
+package hashtest
+
+import (
+	"testing"
+
+	"github.com/fd/go-util/container/consistent_hash"
+)
+
+func TestRing(t *testing.T) {
+	Run(t, Config{
+		Build: func(nodes []consistent_hash.Node) (consistent_hash.Locator, error) {
+			r, err := consistent_hash.NewWithOptions(nodes, consistent_hash.Buckets(200))
+			return &r, err
+		},
+	})
+}
+
+func TestMultiProbe(t *testing.T) {
+	Run(t, Config{
+		Build: func(nodes []consistent_hash.Node) (consistent_hash.Locator, error) {
+			return consistent_hash.NewMultiProbeWithOptions(nodes)
+		},
+	})
+}
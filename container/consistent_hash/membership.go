@@ -0,0 +1,362 @@
+package consistent_hash
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A MembershipSource reports the nodes that should currently be part of a Ring.
+type MembershipSource interface {
+	Members() ([]Node, error)
+}
+
+// A Node identified by a plain string like an IP:PORT
+type NodeID string
+
+func (id NodeID) HashID() string {
+	return string(id)
+}
+
+// A fixed list of nodes
+type StaticSource []Node
+
+func (s StaticSource) Members() ([]Node, error) {
+	return []Node(s), nil
+}
+
+// Read the nodes from a file. The file contains either a JSON array of node
+// ids or one node id per line (blank lines and lines starting with # are
+// ignored). The file is only parsed again when its contents change.
+type FileSource struct {
+	Path string
+
+	mtx   sync.Mutex
+	sum   [sha1.Size]byte
+	nodes []Node
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+func (s *FileSource) Members() ([]Node, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the size and modification time may not change when the file is
+	// rewritten quickly
+	sum := sha1.Sum(data)
+	if s.nodes != nil && sum == s.sum {
+		return s.nodes, nil
+	}
+
+	nodes, err := parse_node_list(data)
+	if err != nil {
+		return nil, fmt.Errorf("consistent_hash: %s: %s", s.Path, err)
+	}
+
+	s.sum = sum
+	s.nodes = nodes
+	return nodes, nil
+}
+
+func parse_node_list(data []byte) ([]Node, error) {
+	var (
+		ids []string
+	)
+
+	data = bytes.TrimSpace(data)
+
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return nil, err
+		}
+	} else {
+		s := bufio.NewScanner(bytes.NewReader(data))
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			ids = append(ids, line)
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	nodes := make([]Node, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, NodeID(id))
+	}
+
+	return nodes, nil
+}
+
+// Resolver performs DNS SRV lookups. *net.Resolver implements this interface.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Discover nodes using DNS SRV records. Each record becomes a node with
+// the id TARGET:PORT.
+type SRVSource struct {
+	Service string
+	Proto   string
+	Name    string
+
+	// Resolver defaults to net.DefaultResolver
+	Resolver Resolver
+
+	// Timeout for a single lookup (defaults to 5s)
+	Timeout time.Duration
+}
+
+func (s *SRVSource) Members() ([]Node, error) {
+	var (
+		resolver = s.Resolver
+		timeout  = s.Timeout
+	)
+
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, addrs, err := resolver.LookupSRV(ctx, s.Service, s.Proto, s.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, 0, len(addrs))
+	for _, addr := range addrs {
+		host := strings.TrimSuffix(addr.Target, ".")
+		nodes = append(nodes, NodeID(net.JoinHostPort(host, strconv.Itoa(int(addr.Port)))))
+	}
+
+	return nodes, nil
+}
+
+// A Change describes a rebuild of the Ring of a Membership.
+type Change struct {
+	Ring    Ring
+	Added   []Node
+	Removed []Node
+}
+
+// Membership keeps a Ring in sync with a MembershipSource.
+type Membership struct {
+	source  MembershipSource
+	buckets uint16
+
+	mtx       sync.RWMutex
+	notify    sync.Mutex // held while calling on_change
+	ring      Ring
+	members   map[string]Node
+	on_change []func(Change)
+	on_error  []func(error)
+	stop      chan struct{}
+	done      chan struct{} // closed when the poll goroutine exits
+}
+
+// Make a new Membership and load the initial set of nodes from src.
+func NewMembership(src MembershipSource, buckets uint16) (*Membership, error) {
+	m := &Membership{source: src, buckets: buckets}
+
+	if _, err := m.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// The current Ring
+func (m *Membership) Ring() Ring {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.ring
+}
+
+// Call f each time the Ring is rebuilt. Changes are delivered one at a time
+// in the order in which they were made; f must not call Refresh.
+func (m *Membership) OnChange(f func(Change)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.on_change = append(m.on_change, f)
+}
+
+// Call f each time polling the source fails.
+func (m *Membership) OnError(f func(error)) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.on_error = append(m.on_error, f)
+}
+
+// Load the nodes from the source and rebuild the Ring when they changed.
+// When the source fails (or reports no nodes) the current Ring is kept.
+func (m *Membership) Refresh() (changed bool, err error) {
+	nodes, err := m.source.Members()
+	if err != nil {
+		return false, err
+	}
+
+	if len(nodes) == 0 {
+		return false, fmt.Errorf("consistent_hash: membership source reported no nodes")
+	}
+
+	members := make(map[string]Node, len(nodes))
+	for _, n := range nodes {
+		members[n.HashID()] = n
+	}
+
+	m.mtx.Lock()
+
+	var (
+		added   = diff_members(members, m.members)
+		removed = diff_members(m.members, members)
+	)
+
+	if m.members != nil && len(added) == 0 && len(removed) == 0 {
+		m.mtx.Unlock()
+		return false, nil
+	}
+
+	ids := make([]string, 0, len(members))
+	for id := range members {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	unique := make([]Node, len(ids))
+	for i, id := range ids {
+		unique[i] = members[id]
+	}
+
+	ring, err := NewWithOptions(unique, Buckets(m.buckets))
+	if err != nil {
+		m.mtx.Unlock()
+		return false, err
+	}
+
+	m.ring = ring
+	m.members = members
+
+	var (
+		change    = Change{m.ring, added, removed}
+		callbacks = m.on_change
+	)
+
+	// taking notify before releasing mtx delivers the changes in order
+	m.notify.Lock()
+	defer m.notify.Unlock()
+	m.mtx.Unlock()
+
+	for _, f := range callbacks {
+		f(change)
+	}
+
+	return true, nil
+}
+
+// Refresh the Ring every interval until Stop() is called. Poll does nothing
+// when the Membership is already polling.
+func (m *Membership) Poll(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("consistent_hash: poll interval must be positive (got %s)", interval)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.stop != nil {
+		return nil
+	}
+
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.poll(interval, m.stop, m.done)
+	return nil
+}
+
+// Stop polling the source. Stop waits for a running Refresh (and its
+// callbacks) to finish, so it must not be called from an OnChange or
+// OnError callback.
+func (m *Membership) Stop() {
+	m.mtx.Lock()
+	stop, done := m.stop, m.done
+	m.stop, m.done = nil, nil
+	m.mtx.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (m *Membership) poll(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := m.Refresh(); err != nil {
+			m.mtx.RLock()
+			callbacks := m.on_error
+			m.mtx.RUnlock()
+
+			for _, f := range callbacks {
+				f(err)
+			}
+		}
+	}
+}
+
+// nodes in a but not in b, sorted by id
+func diff_members(a, b map[string]Node) []Node {
+	var (
+		ids []string
+	)
+
+	for id := range a {
+		if _, found := b[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	o := make([]Node, len(ids))
+	for i, id := range ids {
+		o[i] = a[id]
+	}
+
+	return o
+}
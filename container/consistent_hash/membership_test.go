@@ -0,0 +1,222 @@
+package consistent_hash
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStaticMembership(t *testing.T) {
+	m, err := NewMembership(StaticSource(build_nodes(8)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring := m.Ring()
+	if nodes := ring.Lookup([]byte("hello"), ring.MakeBuffer(-1)); len(nodes) != 8 {
+		t.Fatalf("expected 8 nodes, got %d", len(nodes))
+	}
+
+	changed, err := m.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Fatal("expected static source to be unchanged")
+	}
+}
+
+func TestFileMembership(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consistent_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nodes")
+	write := func(data string, mtime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	write("# cache nodes\n10.0.0.1:11211\n\n10.0.0.2:11211\n", now)
+
+	m, err := NewMembership(NewFileSource(path), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []Change
+	m.OnChange(func(c Change) { changes = append(changes, c) })
+
+	write(`["10.0.0.2:11211", "10.0.0.3:11211"]`, now.Add(time.Second))
+
+	changed, err := m.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(changes) != 1 {
+		t.Fatalf("expected one change, got %d", len(changes))
+	}
+
+	c := changes[0]
+	if len(c.Added) != 1 || c.Added[0].HashID() != "10.0.0.3:11211" {
+		t.Errorf("unexpected added nodes: %v", c.Added)
+	}
+	if len(c.Removed) != 1 || c.Removed[0].HashID() != "10.0.0.1:11211" {
+		t.Errorf("unexpected removed nodes: %v", c.Removed)
+	}
+	if len(c.Ring.nodes) != 2 {
+		t.Errorf("expected 2 nodes in the ring, got %d", len(c.Ring.nodes))
+	}
+
+	// same size and modification time, different contents
+	write(`["10.0.0.4:11211", "10.0.0.3:11211"]`, now.Add(time.Second))
+	if changed, err := m.Refresh(); err != nil || !changed {
+		t.Fatalf("expected the new contents to be read, got %v %v", changed, err)
+	}
+
+	write("", now.Add(2*time.Second))
+	if _, err := m.Refresh(); err == nil {
+		t.Error("expected an error for an empty node list")
+	}
+	if len(m.Ring().nodes) != 2 {
+		t.Error("expected the previous ring to be kept")
+	}
+}
+
+type counting_source_t struct {
+	mtx sync.Mutex
+	n   int
+}
+
+// every call replaces the last node with a new one
+func (s *counting_source_t) Members() ([]Node, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.n++
+	return []Node{NodeID("a"), NodeID("b"), NodeID(fmt.Sprintf("n-%d", s.n))}, nil
+}
+
+func TestMembershipChangeOrder(t *testing.T) {
+	m, err := NewMembership(&counting_source_t{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []Change
+	m.OnChange(func(c Change) {
+		// give concurrent refreshes a chance to overtake this one
+		time.Sleep(time.Millisecond)
+		changes = append(changes, c)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Refresh()
+		}()
+	}
+	wg.Wait()
+
+	for i := 1; i < len(changes); i++ {
+		if changes[i].Removed[0].HashID() != changes[i-1].Added[0].HashID() {
+			t.Fatalf("change %d removes %s but %s was added last", i, changes[i].Removed[0], changes[i-1].Added[0])
+		}
+	}
+
+	last := changes[len(changes)-1].Ring
+	if ring := m.Ring(); ring.OwnerString("hello") != last.OwnerString("hello") || len(ring.nodes) != len(last.nodes) {
+		t.Error("expected the last change to carry the current ring")
+	}
+	if len(changes) != 50 {
+		t.Errorf("expected 50 changes, got %d", len(changes))
+	}
+}
+
+func TestMembershipPoll(t *testing.T) {
+	m, err := NewMembership(&counting_source_t{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Poll(0); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+
+	var (
+		mtx     sync.Mutex
+		changes int
+	)
+
+	m.OnChange(func(c Change) {
+		mtx.Lock()
+		changes++
+		mtx.Unlock()
+	})
+
+	if err := m.Poll(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	m.Stop()
+
+	mtx.Lock()
+	n := changes
+	mtx.Unlock()
+
+	if n == 0 {
+		t.Fatal("expected polling to refresh the ring")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	mtx.Lock()
+	defer mtx.Unlock()
+	if changes != n {
+		t.Errorf("expected no changes after Stop, got %d more", changes-n)
+	}
+}
+
+func TestSRVMembership(t *testing.T) {
+	resolver := &mock_resolver{addrs: []*net.SRV{
+		{Target: "cache-1.example.com.", Port: 11211},
+		{Target: "cache-2.example.com.", Port: 11211},
+	}}
+
+	src := &SRVSource{Service: "memcache", Proto: "tcp", Name: "example.com", Resolver: resolver}
+
+	nodes, err := src.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[0].HashID() != "cache-1.example.com:11211" {
+		t.Fatalf("unexpected nodes: %v", nodes)
+	}
+	if resolver.name != "example.com" || resolver.service != "memcache" {
+		t.Errorf("unexpected lookup: %s %s", resolver.service, resolver.name)
+	}
+}
+
+type mock_resolver struct {
+	service, proto, name string
+	addrs                []*net.SRV
+}
+
+func (r *mock_resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.service, r.proto, r.name = service, proto, name
+	return "", r.addrs, nil
+}
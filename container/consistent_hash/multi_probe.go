@@ -0,0 +1,211 @@
+package consistent_hash
+
+import (
+	"sort"
+)
+
+// The number of probes recommended by Appleton & O'Reilly for a
+// peak-to-mean ratio of about 1.05.
+const DefaultProbes = 21
+
+// MultiProbe implements multi-probe consistent hashing
+// (Appleton & O'Reilly, 2015). Each node is placed on the ring exactly once
+// and each key is hashed probes times; the node closest to any of
+// the probes owns the key. The replicas are the nodes following the owner
+// on the ring.
+type MultiProbe struct {
+	nodes   []Node
+	entries []probe_entry_t
+	probes  int
+}
+
+type probe_entry_t struct {
+	entry_hash uint64
+	node_idx   uint32
+}
+
+// The number of probes per key used by NewMultiProbeWithOptions (defaults
+// to DefaultProbes). Only MultiProbe uses this option; it ignores the
+// other options.
+func Probes(n int) Option {
+	return func(o *options) { o.probes = n }
+}
+
+// Make a new MultiProbe. When probes < 1 DefaultProbes is used.
+// NewMultiProbe panics when the nodes are invalid; use
+// NewMultiProbeWithOptions to handle errors.
+func NewMultiProbe(l []Node, probes int) MultiProbe {
+	r, err := NewMultiProbeWithOptions(l, Probes(probes))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Make a new MultiProbe. The nodes must be non-nil and have distinct,
+// non-empty HashIDs. An empty list of nodes results in an empty
+// MultiProbe.
+func NewMultiProbeWithOptions(l []Node, opts ...Option) (MultiProbe, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.probes < 1 {
+		o.probes = DefaultProbes
+	}
+
+	if err := validate_nodes(l); err != nil {
+		return MultiProbe{}, err
+	}
+
+	e := make([]probe_entry_t, len(l))
+	for i, n := range l {
+		e[i] = probe_entry_t{
+			entry_hash: hash64_string(n.HashID()),
+			node_idx:   uint32(i),
+		}
+	}
+
+	sort.Sort(probe_entry_sorter(e))
+
+	return MultiProbe{l, e, o.probes}, nil
+}
+
+func (r MultiProbe) MakeBuffer(n int) []Node {
+	l := len(r.nodes)
+
+	if n < 1 {
+		n = l
+	} else if n > l {
+		n = l
+	}
+
+	return make([]Node, 0, n)
+}
+
+func (r MultiProbe) Lookup(key []byte, b []Node) []Node {
+	return r.lookup(hash64_bytes(key), b)
+}
+
+// Like Lookup but for string keys (without allocating)
+func (r MultiProbe) LookupString(key string, b []Node) []Node {
+	return r.lookup(hash64_string(key), b)
+}
+
+// Like Lookup but for keys that are already hashed.
+func (r MultiProbe) LookupHash64(hash uint64, b []Node) []Node {
+	return r.lookup(mix64(hash), b)
+}
+
+// The primary node for key
+func (r MultiProbe) Owner(key []byte) Node {
+	if len(r.entries) == 0 {
+		return nil
+	}
+	return r.nodes[r.entries[r.closest(hash64_bytes(key))].node_idx]
+}
+
+// The primary node for a string key (without allocating)
+func (r MultiProbe) OwnerString(key string) Node {
+	if len(r.entries) == 0 {
+		return nil
+	}
+	return r.nodes[r.entries[r.closest(hash64_string(key))].node_idx]
+}
+
+func (r MultiProbe) lookup(hash uint64, b []Node) []Node {
+	if len(r.entries) == 0 {
+		return b[:0]
+	}
+
+	idx := r.closest(hash)
+
+	n := cap(b)
+	if n > len(r.entries) {
+		n = len(r.entries)
+	}
+
+	b = b[:n]
+
+	for i := range b {
+		b[i] = r.nodes[r.entries[idx].node_idx]
+		idx++
+		if idx == len(r.entries) {
+			idx = 0
+		}
+	}
+
+	return b
+}
+
+// find the index of the entry closest to any of the probes for hash.
+// the probes are generated using double hashing.
+func (r MultiProbe) closest(hash uint64) int {
+	var (
+		h1        = hash
+		h2        = mix64(hash^0x9e3779b97f4a7c15) | 1
+		best      = 0
+		best_dist = ^uint64(0)
+	)
+
+	for i := 0; i < r.probes; i++ {
+		probe := h1 + uint64(i)*h2
+
+		idx := sort.Search(len(r.entries), func(i int) bool {
+			return r.entries[i].entry_hash >= probe
+		})
+
+		// wrap around to the first entry
+		if idx == len(r.entries) {
+			idx = 0
+		}
+
+		dist := r.entries[idx].entry_hash - probe
+		if dist < best_dist {
+			best, best_dist = idx, dist
+		}
+	}
+
+	return best
+}
+
+type probe_entry_sorter []probe_entry_t
+
+func (s probe_entry_sorter) Len() int           { return len(s) }
+func (s probe_entry_sorter) Less(i, j int) bool { return s[i].entry_hash < s[j].entry_hash }
+func (s probe_entry_sorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+const (
+	c_FNV64_OFFSET = 14695981039346656037
+	c_FNV64_PRIME  = 1099511628211
+)
+
+// FNV-1a followed by a finalizer to spread the bits over the full range.
+func hash64_bytes(b []byte) uint64 {
+	h := uint64(c_FNV64_OFFSET)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= c_FNV64_PRIME
+	}
+	return mix64(h)
+}
+
+func hash64_string(s string) uint64 {
+	h := uint64(c_FNV64_OFFSET)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= c_FNV64_PRIME
+	}
+	return mix64(h)
+}
+
+// the splitmix64 finalizer
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
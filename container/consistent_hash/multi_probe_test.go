@@ -0,0 +1,149 @@
+package consistent_hash
+
+import (
+	"strconv"
+	"testing"
+	"testing/quick"
+	"unsafe"
+)
+
+func TestMultiProbe(t *testing.T) {
+	nodes := build_nodes(16)
+	ring := NewMultiProbe(nodes, 0)
+
+	t.Log(ring.Lookup([]byte("hello"), ring.MakeBuffer(3)))
+
+	buf := ring.MakeBuffer(-1)
+	f := func(k []byte) bool {
+		nodes := ring.Lookup(k, buf)
+
+		if len(nodes) != 16 {
+			return false
+		}
+
+		seen := map[Node]bool{}
+		for _, n := range nodes {
+			if n == nil || seen[n] {
+				return false
+			}
+			seen[n] = true
+		}
+
+		return true
+	}
+
+	if e := quick.Check(f, nil); e != nil {
+		t.Fatal(e)
+	}
+
+	invalid := map[string][]Node{
+		"duplicate": {NodeID("a"), NodeID("a"), NodeID("b")},
+		"empty id":  {NodeID("a"), NodeID("")},
+		"nil":       {NodeID("a"), nil},
+	}
+
+	for name, nodes := range invalid {
+		if _, err := NewMultiProbeWithOptions(nodes); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if r, err := NewMultiProbeWithOptions(build_nodes(4), Probes(3)); err != nil || r.probes != 3 {
+		t.Errorf("expected 3 probes, got %d (%v)", r.probes, err)
+	}
+
+	empty := NewMultiProbe(nil, 0)
+	if nodes := empty.Lookup([]byte("hello"), empty.MakeBuffer(-1)); len(nodes) != 0 {
+		t.Fatalf("expected no nodes, got %v", nodes)
+	}
+}
+
+func BenchmarkMultiProbeLookup_128_21(b *testing.B) {
+	nodes := build_nodes(128)
+	ring := NewMultiProbe(nodes, 21)
+	k := []byte("hello")
+	b.ResetTimer()
+
+	buf := ring.MakeBuffer(-1)
+
+	for i := 0; i < b.N; i++ {
+		ring.Lookup(k, buf)
+	}
+}
+
+func BenchmarkDistribution_Ring_128_100(b *testing.B) {
+	ring := New(build_nodes(128), 100)
+	bench_distribution(b, &ring, ring_mem_size(ring))
+}
+
+func BenchmarkDistribution_Ring_128_1024(b *testing.B) {
+	ring := New(build_nodes(128), 1024)
+	bench_distribution(b, &ring, ring_mem_size(ring))
+}
+
+func BenchmarkDistribution_MultiProbe_128_5(b *testing.B) {
+	ring := NewMultiProbe(build_nodes(128), 5)
+	bench_distribution(b, ring, multi_probe_mem_size(ring))
+}
+
+func BenchmarkDistribution_MultiProbe_128_21(b *testing.B) {
+	ring := NewMultiProbe(build_nodes(128), 21)
+	bench_distribution(b, ring, multi_probe_mem_size(ring))
+}
+
+func BenchmarkDistribution_MultiProbe_128_50(b *testing.B) {
+	ring := NewMultiProbe(build_nodes(128), 50)
+	bench_distribution(b, ring, multi_probe_mem_size(ring))
+}
+
+// report the memory used by the ring, the peak-to-mean ratio of the
+// primary owners and the lookup speed.
+func bench_distribution(b *testing.B, l Locator, mem_size uintptr) {
+	var (
+		buf = l.MakeBuffer(1)
+		k   = []byte("hello")
+	)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Lookup(k, buf)
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(mem_size), "ring-bytes")
+	b.ReportMetric(peak_to_mean(l, 100000), "peak/mean")
+}
+
+func peak_to_mean(l Locator, keys int) float64 {
+	var (
+		counts = map[string]int{}
+		buf    = l.MakeBuffer(1)
+		peak   int
+	)
+
+	for i := 0; i < keys; i++ {
+		nodes := l.Lookup([]byte("key-"+strconv.Itoa(i)), buf)
+		counts[nodes[0].HashID()]++
+	}
+
+	for _, c := range counts {
+		if c > peak {
+			peak = c
+		}
+	}
+
+	mean := float64(keys) / float64(cap(l.MakeBuffer(-1)))
+	return float64(peak) / mean
+}
+
+func ring_mem_size(r Ring) uintptr {
+	size := uintptr(len(r.entries)) * unsafe.Sizeof(entry_t{})
+	for _, e := range r.entries {
+		size += uintptr(len(e.ring))
+	}
+	return size
+}
+
+func multi_probe_mem_size(r MultiProbe) uintptr {
+	return uintptr(len(r.entries)) * unsafe.Sizeof(probe_entry_t{})
+}
@@ -2,12 +2,13 @@ package consistent_hash
 
 import (
 	"hash/crc32"
-	"sort"
 )
 
+// The zero Ring is an empty ring; its lookups return no nodes.
 type Ring struct {
 	nodes   []Node
 	entries []entry_t
+	hash    func([]byte) uint32
 }
 
 type Node interface {
@@ -15,6 +16,13 @@ type Node interface {
 	HashID() string
 }
 
+// A Locator maps keys to an ordered list of nodes where the first node
+// is the primary owner of the key and the others are its replicas.
+type Locator interface {
+	MakeBuffer(n int) []Node
+	Lookup(key []byte, b []Node) []Node
+}
+
 type entry_t struct {
 	node_idx   uint8
 	entry_hash uint32
@@ -34,19 +42,28 @@ func (r *Ring) MakeBuffer(n int) []Node {
 }
 
 func (r Ring) Lookup(key []byte, b []Node) []Node {
-	hash := crc32.ChecksumIEEE(key)
+	return r.LookupHash(r.hash_key(key), b)
+}
+
+// Like Lookup but for string keys (without allocating when the ring uses
+// the default hash)
+func (r Ring) LookupString(key string, b []Node) []Node {
+	return r.LookupHash(r.hash_string(key), b)
+}
 
-	idx := sort.Search(len(r.entries), func(i int) bool {
-		return r.entries[i].entry_hash >= hash
-	})
+// Like Lookup but for keys that are already hashed to 64 bits.
+// The hash is folded into the 32 bit space of the ring.
+func (r Ring) LookupHash64(hash uint64, b []Node) []Node {
+	return r.LookupHash(fold64(hash), b)
+}
 
-	// idx == len(r.entries) when the hash is before the first entry
-	// in this case the last entry must be used
-	if idx == len(r.entries) {
-		idx--
+// Like Lookup but for keys that are already hashed.
+func (r Ring) LookupHash(hash uint32, b []Node) []Node {
+	if len(r.entries) == 0 {
+		return b[:0]
 	}
 
-	ring := r.entries[idx].ring
+	ring := r.entries[r.search(hash)].ring
 	ring_len := len(ring)
 	n := cap(b)
 
@@ -64,3 +81,68 @@ func (r Ring) Lookup(key []byte, b []Node) []Node {
 
 	return b
 }
+
+// The primary node for key
+func (r Ring) Owner(key []byte) Node {
+	return r.OwnerHash(r.hash_key(key))
+}
+
+// The primary node for a string key (without allocating when the ring
+// uses the default hash)
+func (r Ring) OwnerString(key string) Node {
+	return r.OwnerHash(r.hash_string(key))
+}
+
+// The primary node for an already hashed key (nil when the ring is empty)
+func (r Ring) OwnerHash(hash uint32) Node {
+	if len(r.entries) == 0 {
+		return nil
+	}
+	return r.nodes[r.entries[r.search(hash)].ring[0]]
+}
+
+func (r Ring) hash_key(key []byte) uint32 {
+	if r.hash == nil {
+		return crc32.ChecksumIEEE(key)
+	}
+	return r.hash(key)
+}
+
+func (r Ring) search(hash uint32) int {
+	// inlined sort.Search (avoids allocating the closure)
+	i, j := 0, len(r.entries)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if r.entries[h].entry_hash < hash {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+
+	// i == len(r.entries) when the hash is after the last entry
+	// in this case the last entry must be used
+	if i == len(r.entries) {
+		i--
+	}
+
+	return i
+}
+
+func fold64(hash uint64) uint32 {
+	return uint32(hash>>32) ^ uint32(hash)
+}
+
+// hash_key for a string key. The default hash is computed over the string
+// itself (crc32.ChecksumIEEE needs a []byte which would escape).
+func (r Ring) hash_string(key string) uint32 {
+	if r.hash != nil {
+		return r.hash([]byte(key))
+	}
+
+	crc := ^uint32(0)
+	for i := 0; i < len(key); i++ {
+		crc = crc32.IEEETable[byte(crc)^key[i]] ^ (crc >> 8)
+	}
+	return ^crc
+}
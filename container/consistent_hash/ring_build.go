@@ -1,35 +1,171 @@
 package consistent_hash
 
 import (
+	"errors"
+	"fmt"
 	"hash/crc32"
 	"sort"
 )
 
+// The number of buckets per node used by NewWithOptions
+const DefaultBuckets = 100
+
+// The maximum number of nodes in a Ring
+const MaxNodes = 256
+
+var (
+	ErrTooManyNodes = errors.New("consistent_hash: too many nodes")
+)
+
+type Option func(*options)
+
+type options struct {
+	buckets      uint16
+	hash         func([]byte) uint32
+	max_replicas int
+	legacy       bool
+	probes       int
+}
+
+// The number of buckets (virtual nodes) per node
+func Buckets(n uint16) Option {
+	return func(o *options) { o.buckets = n }
+}
+
+// The function used to hash keys (defaults to crc32.ChecksumIEEE). The
+// function must not retain the key.
+func Hasher(f func([]byte) uint32) Option {
+	return func(o *options) { o.hash = f }
+}
+
+// The maximum number of nodes returned by a lookup. Limiting the replicas
+// reduces the memory used by the ring.
+func MaxReplicas(n int) Option {
+	return func(o *options) { o.max_replicas = n }
+}
+
+// Place the entries and build the replica lists like Rings built before
+// the placement fix (see the package documentation), so that existing
+// keys keep their owners and replicas. The legacy placement puts all
+// buckets of a node at the same point and spreads keys poorly; only use it
+// while migrating.
+func LegacyPlacement() Option {
+	return func(o *options) { o.legacy = true }
+}
+
+// Make a new Ring. New panics when the nodes are invalid; use NewWithOptions
+// to handle errors.
 func New(l []Node, buckets uint16) Ring {
-	e := wrap_nodes(l, buckets)
+	r, err := NewWithOptions(l, Buckets(buckets))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Make a new Ring. An empty list of nodes results in an empty ring.
+func NewWithOptions(l []Node, opts ...Option) (Ring, error) {
+	o := options{buckets: DefaultBuckets}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := validate(l, o); err != nil {
+		return Ring{}, err
+	}
+
+	if len(l) == 0 {
+		return Ring{hash: o.hash}, nil
+	}
+
+	ring_len := len(l)
+	if o.max_replicas > 0 && o.max_replicas < ring_len {
+		ring_len = o.max_replicas
+	}
+
+	e := wrap_nodes(l, o.buckets, o.legacy)
 	e = sort_entries(e)
-	e = make_entry_rings(e, len(l))
-	return Ring{l, e}
+	if o.legacy {
+		e = make_legacy_entry_rings(e, len(l), ring_len)
+	} else {
+		e = make_entry_rings(e, len(l), ring_len)
+	}
+	return Ring{l, e, o.hash}, nil
+}
+
+func validate(l []Node, o options) error {
+	if o.buckets == 0 {
+		return errors.New("consistent_hash: buckets must be at least 1")
+	}
+
+	if o.max_replicas < 0 {
+		return errors.New("consistent_hash: max replicas must not be negative")
+	}
+
+	if len(l) > MaxNodes {
+		return ErrTooManyNodes
+	}
+
+	return validate_nodes(l)
 }
 
-func wrap_nodes(l []Node, buckets uint16) []entry_t {
+// the nodes must be non-nil and have distinct, non-empty HashIDs
+func validate_nodes(l []Node) error {
+	seen := make(map[string]bool, len(l))
+	for i, n := range l {
+		if n == nil {
+			return fmt.Errorf("consistent_hash: node %d is nil", i)
+		}
+
+		id := n.HashID()
+		if id == "" {
+			return fmt.Errorf("consistent_hash: node %d has an empty HashID", i)
+		}
+		if seen[id] {
+			return fmt.Errorf("consistent_hash: duplicate HashID %q", id)
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+func wrap_nodes(l []Node, buckets uint16, legacy bool) []entry_t {
 	var (
-		o = make([]entry_t, len(l)*int(buckets))
-		b [1024]byte
+		o       = make([]entry_t, len(l)*int(buckets))
+		max_len = 0
 	)
 
+	for _, n := range l {
+		if n := len(n.HashID()); n > max_len {
+			max_len = n
+		}
+	}
+
+	b := make([]byte, max_len+3)
+
 	for i, n := range l {
 		node_id := n.HashID()
 		node_id_bytes := b[:len(node_id)+3]
 		copy(node_id_bytes[2:], node_id+"•")
 
 		for j := 0; j < int(buckets); j++ {
-			node_id_bytes[0] = byte(uint16(j)>>8 | 0xFF)
-			node_id_bytes[1] = byte(uint16(j) | 0xFF)
+			var hash uint32
+
+			if legacy {
+				// (| instead of & hashes every bucket to the same point)
+				node_id_bytes[0] = byte(uint16(j)>>8 | 0xFF)
+				node_id_bytes[1] = byte(uint16(j) | 0xFF)
+				hash = crc32.ChecksumIEEE(node_id_bytes)
+			} else {
+				node_id_bytes[0] = byte(uint16(j) >> 8 & 0xFF)
+				node_id_bytes[1] = byte(uint16(j) & 0xFF)
+				hash = fmix32(crc32.ChecksumIEEE(node_id_bytes))
+			}
 
 			o[i*int(buckets)+j] = entry_t{
 				node_idx:   uint8(i),
-				entry_hash: crc32.ChecksumIEEE(node_id_bytes),
+				entry_hash: hash,
 			}
 		}
 	}
@@ -42,15 +178,52 @@ func sort_entries(l []entry_t) []entry_t {
 	return l
 }
 
-func make_entry_rings(entries []entry_t, l_ring_len int) []entry_t {
+// Each entry gets the list of distinct nodes found walking the ring
+// clockwise from the entry (the node of the entry first). The lists are
+// truncated to ring_len.
+func make_entry_rings(entries []entry_t, n_nodes, ring_len int) []entry_t {
+	var (
+		o    = make([]uint8, len(entries)*ring_len)
+		seen = make([]int, n_nodes)
+	)
+
+	for i := range entries {
+		r := o[ring_len*i : ring_len*(i+1) : ring_len*(i+1)]
+
+		// seen holds i+1 for the nodes already in the ring of entry i
+		j := 0
+		for k := i; j < ring_len; k++ {
+			if k == len(entries) {
+				k = 0
+			}
+
+			node_idx := entries[k].node_idx
+			if seen[node_idx] == i+1 {
+				continue
+			}
+
+			seen[node_idx] = i + 1
+			r[j] = node_idx
+			j++
+		}
+
+		entries[i].ring = r
+	}
+
+	return entries
+}
+
+// The replica lists of LegacyPlacement: the nodes in order of their first
+// entry on the ring, with the node of the entry moved to the front. The
+// list starts out zeroed so node 0 is never found and always comes last
+// (except for the first entry which gets the list as is).
+func make_legacy_entry_rings(entries []entry_t, n_nodes, ring_len int) []entry_t {
 	var (
-		g_ring_len = len(entries)
-		o          = make([]uint8, len(entries)*l_ring_len)
-		i          = 0
+		o = make([]uint8, len(entries)*n_nodes)
+		l = o[:n_nodes]
+		n = 0
 	)
 
-	// build first l_ring
-	l := o[:l_ring_len]
 FIRST_RING:
 	for _, e := range entries {
 		for _, node_idx := range l {
@@ -59,38 +232,30 @@ FIRST_RING:
 			}
 		}
 
-		l[i] = e.node_idx
-		i++
+		l[n] = e.node_idx
+		n++
 	}
-	entries[0].ring = l
+	entries[0].ring = l[:ring_len:ring_len]
 
-	// build other rings
-	for i := g_ring_len - 1; i > 0; i-- {
-		e := entries[i]
-		r := o[l_ring_len*i : l_ring_len*(i+1)]
+	for i := len(entries) - 1; i > 0; i-- {
+		r := o[n_nodes*i : n_nodes*(i+1)]
 
-		if l[0] == e.node_idx {
+		if node_idx := entries[i].node_idx; l[0] == node_idx {
 			copy(r, l)
 		} else {
-			// find idx
 			idx := 0
-			node_idx := uint8(0)
-			for idx, node_idx = range l {
-				if node_idx == e.node_idx {
+			for idx = range l {
+				if l[idx] == node_idx {
 					break
 				}
 			}
 
 			r[0] = l[idx]
 			copy(r[1:idx+1], l[:idx])
-			if idx+1 < l_ring_len {
-				copy(r[idx+1:], l[idx+1:])
-			}
+			copy(r[idx+1:], l[idx+1:])
 		}
 
-		e.ring = r
-		entries[i] = e
-		r = r
+		entries[i].ring = r[:ring_len:ring_len]
 	}
 
 	return entries
@@ -101,3 +266,14 @@ type entry_sorter []entry_t
 func (s entry_sorter) Len() int           { return len(s) }
 func (s entry_sorter) Less(i, j int) bool { return s[i].entry_hash < s[j].entry_hash }
 func (s entry_sorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// the murmur3 finalizer; crc32 alone places the buckets of a node too close
+// to each other.
+func fmix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,117 @@
+package consistent_hash
+
+// A half-open interval [Start, End) of the 32 bit hash space of a Ring.
+// End is 1<<32 for the last interval.
+type Range struct {
+	Start uint64
+	End   uint64
+}
+
+func (r Range) Contains(hash uint32) bool {
+	return r.Start <= uint64(hash) && uint64(hash) < r.End
+}
+
+// An entry of a Ring: the interval of hashes it owns and its nodes
+// (the primary first followed by the replicas).
+type Entry struct {
+	Range Range
+	Nodes []Node
+}
+
+// The sorted intervals of hashes for which the node identified by hashID
+// is at replicaPos in the list of nodes (0 is the primary, 1 the first
+// replica etc). When replicaPos < 0 the intervals for which the node is
+// either the primary or a replica are returned.
+func (r Ring) Ranges(hashID string, replicaPos int) []Range {
+	var (
+		node_idx = -1
+		o        []Range
+	)
+
+	for i, n := range r.nodes {
+		if n.HashID() == hashID {
+			node_idx = i
+			break
+		}
+	}
+
+	if node_idx < 0 {
+		return nil
+	}
+
+	for i, e := range r.entries {
+		if !owns_entry(e.ring, uint8(node_idx), replicaPos) {
+			continue
+		}
+
+		rng := r.entry_range(i)
+		if rng.Start >= rng.End {
+			continue
+		}
+
+		// merge adjacent intervals
+		if l := len(o); l > 0 && o[l-1].End == rng.Start {
+			o[l-1].End = rng.End
+			continue
+		}
+
+		o = append(o, rng)
+	}
+
+	return o
+}
+
+// Call f for each entry of the ring in order of the hash space until
+// f returns false. The Nodes slice is reused between calls.
+func (r Ring) Walk(f func(e Entry) bool) {
+	buf := r.MakeBuffer(-1)
+
+	for i, e := range r.entries {
+		rng := r.entry_range(i)
+		if rng.Start >= rng.End {
+			continue
+		}
+
+		nodes := buf[:len(e.ring)]
+		for j, idx := range e.ring {
+			nodes[j] = r.nodes[idx]
+		}
+
+		if !f(Entry{rng, nodes}) {
+			return
+		}
+	}
+}
+
+// The interval of hashes owned by the entry at i. Lookup maps a hash to the
+// first entry with an entry_hash >= hash and hashes past the last entry to
+// the last entry.
+func (r Ring) entry_range(i int) Range {
+	var rng Range
+
+	if i > 0 {
+		rng.Start = uint64(r.entries[i-1].entry_hash) + 1
+	}
+
+	if i == len(r.entries)-1 {
+		rng.End = 1 << 32
+	} else {
+		rng.End = uint64(r.entries[i].entry_hash) + 1
+	}
+
+	return rng
+}
+
+func owns_entry(ring []uint8, node_idx uint8, replicaPos int) bool {
+	if replicaPos >= 0 {
+		return replicaPos < len(ring) && ring[replicaPos] == node_idx
+	}
+
+	for _, idx := range ring {
+		if idx == node_idx {
+			return true
+		}
+	}
+
+	return false
+}
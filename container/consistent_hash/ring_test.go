@@ -2,6 +2,7 @@ package consistent_hash
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"testing/quick"
 )
@@ -56,6 +57,274 @@ func TestBuild(t *testing.T) {
 	}
 }
 
+func TestNewWithOptions(t *testing.T) {
+	invalid := map[string][]Node{
+		"duplicate": {NodeID("a"), NodeID("b"), NodeID("a")},
+		"empty id":  {NodeID("a"), NodeID("")},
+		"nil":       {NodeID("a"), nil},
+		"too many":  build_nodes(MaxNodes + 1),
+	}
+
+	for name, nodes := range invalid {
+		if _, err := NewWithOptions(nodes); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := NewWithOptions(build_nodes(2), Buckets(0)); err == nil {
+		t.Error("expected an error for 0 buckets")
+	}
+
+	long := make([]byte, 4096)
+	for i := range long {
+		long[i] = 'x'
+	}
+	if _, err := NewWithOptions([]Node{NodeID(long)}); err != nil {
+		t.Errorf("expected long ids to be accepted: %s", err)
+	}
+
+	for _, ring := range []Ring{{}, New(nil, 10)} {
+		if nodes := ring.Lookup([]byte("hello"), ring.MakeBuffer(-1)); len(nodes) != 0 {
+			t.Errorf("expected no nodes, got %v", nodes)
+		}
+		if node := ring.OwnerString("hello"); node != nil {
+			t.Errorf("expected no owner, got %v", node)
+		}
+	}
+
+	ring, err := NewWithOptions(build_nodes(16), Buckets(10), MaxReplicas(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes := ring.Lookup([]byte("hello"), ring.MakeBuffer(-1)); len(nodes) != 3 {
+		t.Errorf("expected 3 nodes, got %v", nodes)
+	}
+
+	var hashed []byte
+	ring, err = NewWithOptions(build_nodes(16), Hasher(func(k []byte) uint32 {
+		hashed = append(hashed[:0], k...)
+		return 42
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.OwnerString("hello") != ring.OwnerHash(42) || string(hashed) != "hello" {
+		t.Error("expected the custom hasher to be used")
+	}
+}
+
+func TestRanges(t *testing.T) {
+	var (
+		nodes = build_nodes(8)
+		ring  = New(nodes, 10)
+		buf   = ring.MakeBuffer(-1)
+		total uint64
+	)
+
+	for _, n := range nodes {
+		for pos := 0; pos < 2; pos++ {
+			ranges := ring.Ranges(n.HashID(), pos)
+
+			for i, rng := range ranges {
+				if i > 0 && ranges[i-1].End >= rng.Start {
+					t.Fatalf("ranges are not sorted and disjoint: %v", ranges)
+				}
+
+				for _, h := range []uint64{rng.Start, (rng.Start + rng.End) / 2, rng.End - 1} {
+					if owner := ring.LookupHash(uint32(h), buf)[pos]; owner != n {
+						t.Fatalf("expected %v at %d for hash %x, got %v", n, pos, h, owner)
+					}
+				}
+
+				if pos == 0 {
+					total += rng.End - rng.Start
+				}
+			}
+		}
+	}
+
+	if total != 1<<32 {
+		t.Errorf("primary ranges don't cover the hash space: %d", total)
+	}
+
+	var (
+		entries int
+		last    uint64
+	)
+
+	ring.Walk(func(e Entry) bool {
+		if e.Range.Start != last {
+			t.Fatalf("expected entry to start at %x, got %x", last, e.Range.Start)
+		}
+		if len(e.Nodes) != 8 || ring.LookupHash(uint32(e.Range.Start), buf)[0] != e.Nodes[0] {
+			t.Fatalf("unexpected nodes for %v: %v", e.Range, e.Nodes)
+		}
+		last = e.Range.End
+		entries++
+		return true
+	})
+
+	if last != 1<<32 || entries == 0 {
+		t.Errorf("entries don't cover the hash space")
+	}
+
+	if ranges := ring.Ranges("unknown", -1); ranges != nil {
+		t.Errorf("expected no ranges for an unknown node, got %v", ranges)
+	}
+}
+
+func TestReplicaBalance(t *testing.T) {
+	const (
+		n_nodes = 16
+		n_keys  = 100000
+	)
+
+	var (
+		ring   = New(build_nodes(n_nodes), 100)
+		buf    = ring.MakeBuffer(-1)
+		counts [n_nodes][n_nodes]int
+	)
+
+	for i := 0; i < n_keys; i++ {
+		for pos, n := range ring.LookupString(fmt.Sprintf("key-%d", i), buf) {
+			counts[pos][n.(*mock_node).i]++
+		}
+	}
+
+	// every node should hold roughly 1/n_nodes of the keys at every
+	// position. The last positions are decided by the few largest gaps
+	// between the buckets of a node so they get a wider margin.
+	mean := n_keys / n_nodes
+	for pos := 1; pos < n_nodes; pos++ {
+		lo, hi := mean*3/5, mean*7/5
+		if pos > n_nodes/2 {
+			lo, hi = mean/3, mean*2
+		}
+
+		for node, c := range counts[pos] {
+			if c < lo || c > hi {
+				t.Errorf("position %d: node %d holds %d keys (expected about %d)", pos, node, c, mean)
+			}
+		}
+	}
+}
+
+func TestLegacyPlacement(t *testing.T) {
+	// lookups of key-0 ... key-11 made by the original implementation
+	expected := []string{
+		"2 4 3 5 1", "1 4 3 2 5", "2 4 3 5 1", "5 4 3 2 1",
+		"2 4 3 5 1", "3 4 2 5 1", "2 4 3 5 1", "5 4 3 2 1",
+		"2 4 3 5 1", "3 4 2 5 1", "1 4 3 2 5", "1 4 3 2 5",
+	}
+
+	var nodes []Node
+	for i := 1; i <= 5; i++ {
+		nodes = append(nodes, NodeID(fmt.Sprintf("10.0.0.%d:11211", i)))
+	}
+
+	ring, err := NewWithOptions(nodes, Buckets(10), LegacyPlacement())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := ring.MakeBuffer(-1)
+	for i, e := range expected {
+		var ids []string
+		for _, n := range ring.LookupString(fmt.Sprintf("key-%d", i), buf) {
+			ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(n.HashID(), "10.0.0."), ":11211"))
+		}
+
+		if s := strings.Join(ids, " "); s != e {
+			t.Errorf("key-%d: expected %s, got %s", i, e, s)
+		}
+	}
+
+	ring, err = NewWithOptions(nodes, Buckets(10), LegacyPlacement(), MaxReplicas(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes := ring.LookupString("key-1", ring.MakeBuffer(-1)); len(nodes) != 2 || nodes[1].HashID() != "10.0.0.4:11211" {
+		t.Errorf("expected the legacy replicas to be truncated, got %v", nodes)
+	}
+}
+
+func TestLookupWithoutAllocation(t *testing.T) {
+	var (
+		ring  = New(build_nodes(128), 100)
+		probe = NewMultiProbe(build_nodes(128), 21)
+		buf   = ring.MakeBuffer(3)
+		key   = "hello"
+	)
+
+	if a := ring.LookupString(key, buf); a[0] != ring.Lookup([]byte(key), buf)[0] {
+		t.Error("LookupString and Lookup disagree")
+	}
+	if ring.OwnerString(key) != ring.Owner([]byte(key)) {
+		t.Error("OwnerString and Owner disagree")
+	}
+	if probe.OwnerString(key) != probe.Owner([]byte(key)) {
+		t.Error("OwnerString and Owner disagree")
+	}
+
+	f := func(k string) bool { return ring.hash_string(k) == ring.hash_key([]byte(k)) }
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+
+	funcs := map[string]func(){
+		"Ring.LookupString":       func() { ring.LookupString(key, buf) },
+		"Ring.LookupHash":         func() { ring.LookupHash(0xdeadbeef, buf) },
+		"Ring.LookupHash64":       func() { ring.LookupHash64(0xdeadbeefcafebabe, buf) },
+		"Ring.OwnerString":        func() { ring.OwnerString(key) },
+		"MultiProbe.LookupString": func() { probe.LookupString(key, buf) },
+		"MultiProbe.LookupHash64": func() { probe.LookupHash64(0xdeadbeefcafebabe, buf) },
+		"MultiProbe.OwnerString":  func() { probe.OwnerString(key) },
+	}
+
+	for name, f := range funcs {
+		if n := testing.AllocsPerRun(100, f); n != 0 {
+			t.Errorf("%s: expected no allocations, got %v", name, n)
+		}
+	}
+}
+
+func BenchmarkLookupString_128_100(b *testing.B) {
+	nodes := build_nodes(128)
+	ring := New(nodes, 100)
+	k := "hello"
+	buf := ring.MakeBuffer(-1)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ring.LookupString(k, buf)
+	}
+}
+
+func BenchmarkLookupHash64_128_100(b *testing.B) {
+	nodes := build_nodes(128)
+	ring := New(nodes, 100)
+	buf := ring.MakeBuffer(-1)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ring.LookupHash64(uint64(i), buf)
+	}
+}
+
+func BenchmarkOwnerString_128_100(b *testing.B) {
+	nodes := build_nodes(128)
+	ring := New(nodes, 100)
+	k := "hello"
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ring.OwnerString(k)
+	}
+}
+
 func BenchmarkLookup_128_25(b *testing.B) {
 	nodes := build_nodes(128)
 	ring := New(nodes, 25)
@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	context_fields_mtx sync.RWMutex
+	context_fields     []context_field_t
+)
+
+type context_field_t struct {
+	name  string
+	value func(ctx context.Context) interface{}
+}
+
+// Add the value of key in the context to the errors made with NewCtx and
+// AnnotateCtx (as the field name).
+func RegisterContextKey(name string, key interface{}) {
+	RegisterContextField(name, func(ctx context.Context) interface{} {
+		return ctx.Value(key)
+	})
+}
+
+// Add the value returned by f to the errors made with NewCtx and
+// AnnotateCtx (as the field name). f returns nil when ctx has no value.
+func RegisterContextField(name string, f func(ctx context.Context) interface{}) {
+	context_fields_mtx.Lock()
+	defer context_fields_mtx.Unlock()
+
+	context_fields = append(context_fields, context_field_t{name, f})
+}
+
+// Make a new Error with the registered fields of ctx
+func NewCtx(ctx context.Context, message string, args ...interface{}) *Error {
+	return with_ctx(ctx, new_error(1, nil, message, args))
+}
+
+// Wrap err in a new Error with the registered fields of ctx. When err is
+// the error of ctx (context.Canceled or context.DeadlineExceeded) the
+// deadline and the elapsed time are added too (see ContextError).
+func AnnotateCtx(ctx context.Context, err error, message string, args ...interface{}) *Error {
+	if l, ok := err.(List); ok {
+		err = l.Normalize()
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	e := new_error(1, err, message, args)
+
+	if ctx_err := ctx.Err(); ctx_err != nil && Is(err, ctx_err) {
+		with_done(ctx, e)
+	}
+
+	return with_ctx(ctx, e)
+}
+
+type start_time_key struct{}
+
+// Record the start of an operation in the context. ContextError reports
+// the time elapsed since then.
+func WithStartTime(ctx context.Context) context.Context {
+	return context.WithValue(ctx, start_time_key{}, time.Now())
+}
+
+// The error of a done context (nil when ctx is not done) annotated with
+// the deadline (and how long ago it passed) and the time elapsed since
+// WithStartTime.
+func ContextError(ctx context.Context) *Error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+
+	e := new_error(1, err, "context done", nil)
+
+	if start, ok := ctx.Value(start_time_key{}).(time.Time); ok {
+		e.message = fmt.Sprintf("context done after %s", time.Since(start))
+		e.format = "context done after %s"
+	}
+
+	with_done(ctx, e)
+	return with_ctx(ctx, e)
+}
+
+func with_done(ctx context.Context, e *Error) {
+	now := time.Now()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		e.With("deadline", deadline)
+		if now.After(deadline) {
+			e.With("overdue", now.Sub(deadline))
+		}
+	}
+
+	if start, ok := ctx.Value(start_time_key{}).(time.Time); ok {
+		e.With("elapsed", now.Sub(start))
+	}
+}
+
+func with_ctx(ctx context.Context, e *Error) *Error {
+	context_fields_mtx.RLock()
+	defer context_fields_mtx.RUnlock()
+
+	for _, f := range context_fields {
+		if value := f.value(ctx); value != nil {
+			e.With(f.name, value)
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+type request_id_key struct{}
+
+func TestCtx(t *testing.T) {
+	restore_context_fields(t)
+	RegisterContextKey("request_id", request_id_key{})
+
+	ctx := context.WithValue(context.Background(), request_id_key{}, "req-1")
+
+	err := NewCtx(ctx, "user %d", 7)
+	if err.Message() != "user 7" || err.Fields()["request_id"] != "req-1" {
+		t.Errorf("unexpected error: %#v", err)
+	}
+	if f := err.Stack()[0]; f.Function != "TestCtx" {
+		t.Errorf("expected the stack to start at the caller, got %s", f.FuncName())
+	}
+
+	err = AnnotateCtx(context.Background(), io.EOF, "read")
+	if _, found := err.Fields()["request_id"]; found || err.Unwrap() != io.EOF {
+		t.Errorf("unexpected error: %#v", err)
+	}
+
+	if AnnotateCtx(ctx, nil, "nothing") != nil {
+		t.Error("expected nil")
+	}
+}
+
+// restore the registered context fields when t is done
+func restore_context_fields(t *testing.T) {
+	context_fields_mtx.RLock()
+	saved := context_fields
+	context_fields_mtx.RUnlock()
+
+	t.Cleanup(func() {
+		context_fields_mtx.Lock()
+		context_fields = saved
+		context_fields_mtx.Unlock()
+	})
+}
+
+func TestContextError(t *testing.T) {
+	ctx := WithStartTime(context.Background())
+	if ContextError(ctx) != nil {
+		t.Error("expected no error for a context which is not done")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err := ContextError(ctx)
+	fields := err.Fields()
+
+	if !Is(err, context.DeadlineExceeded) || KindOf(err) != KindTimeout {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if _, ok := fields["deadline"].(time.Time); !ok {
+		t.Errorf("expected the deadline, got %v", fields)
+	}
+	if d, ok := fields["elapsed"].(time.Duration); !ok || d < time.Millisecond {
+		t.Errorf("expected the elapsed time, got %v", fields)
+	}
+	if _, ok := fields["overdue"].(time.Duration); !ok {
+		t.Errorf("expected the overdue time, got %v", fields)
+	}
+
+	err = AnnotateCtx(ctx, ctx.Err(), "query")
+	if _, ok := err.Fields()["elapsed"]; !ok {
+		t.Errorf("expected AnnotateCtx to add the elapsed time, got %v", err.Fields())
+	}
+}
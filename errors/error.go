@@ -1,22 +1,24 @@
 package errors
 
 import (
-	"bytes"
 	"fmt"
-	"sort"
 	"strings"
-	"text/tabwriter"
 )
 
 var StackLimit = 3
 var StackContext = 3
 
 type Error struct {
-	err     error
-	message string
-	context []string
-	stack   Stack
-	fatal   bool
+	err        error
+	message    string
+	format     string // the message before formatting (see Fingerprint)
+	public     string // the message for end users (see WithPublic)
+	context    []field_t
+	callers    Callers
+	stack      Stack // only set for decoded errors
+	goroutines []Goroutine
+	fatal      bool
+	kind       Kind
 }
 
 func IsFatal(err error) bool {
@@ -43,34 +45,36 @@ func Guard(f func() error) (err error) {
 	return
 }
 
-// Make a new Error from a panic()
+// Make a new Error from a panic() (with a dump of all goroutines when
+// PanicGoroutines is set)
 func NewFromPanic(r interface{}) *Error {
-	if r == nil {
-		return nil
-	}
-
-	if err, ok := r.(*Error); ok {
-		return Annotate(err, "panic: %s", err.message)
-	}
+	var (
+		e *Error
+	)
 
-	if err, ok := r.(error); ok {
-		return Annotate(err, "panic: %s", err)
+	switch v := r.(type) {
+	case nil:
+		return nil
+	case *Error:
+		e = Annotate(v, "panic: %s", v.message)
+	case error:
+		e = Annotate(v, "panic: %s", v)
+	case string:
+		e = New("panic: %s", v)
+	default:
+		e = New("panic: %+v", r)
 	}
 
-	if msg, ok := r.(string); ok {
-		return New("panic: %s", msg)
+	if PanicGoroutines {
+		e.goroutines = CaptureGoroutines()
 	}
 
-	return New("panic: %+v", r)
+	return e
 }
 
 // Make a new Error
 func New(message string, args ...interface{}) *Error {
-	return &Error{
-		message: fmt.Sprintf(message, args...),
-		stack:   CaptureStack().Skip(2),
-		fatal:   true,
-	}
+	return new_error(1, nil, message, args)
 }
 
 // Wrap err in an new Error
@@ -83,10 +87,17 @@ func Annotate(err error, message string, args ...interface{}) *Error {
 		return nil
 	}
 
+	return new_error(1, err, message, args)
+}
+
+// make a fatal Error wrapping err (which may be nil). skip is the number of
+// frames above new_error to leave out of the stack (like CaptureCallers).
+func new_error(skip int, err error, message string, args []interface{}) *Error {
 	return &Error{
 		err:     err,
 		message: fmt.Sprintf(message, args...),
-		stack:   CaptureStack().Skip(2),
+		format:  message,
+		callers: CaptureCallers(skip + 1),
 		fatal:   true,
 	}
 }
@@ -99,75 +110,69 @@ func (e *Error) SetFatal(flag bool) {
 	e.fatal = flag
 }
 
-// Add some context to an error
-func (e *Error) AddContext(format string, args ...interface{}) {
+// The error wrapped by Annotate (nil for errors made with New)
+func (e *Error) Unwrap() error {
 	if e == nil {
-		return
+		return nil
 	}
 
-	e.context = append(
-		e.context,
-		fmt.Sprintf(format, args...),
-	)
+	return e.err
 }
 
-func (e *Error) Error() string {
+// The message of the error (without the messages of its causes)
+func (e *Error) Message() string {
 	if e == nil {
-		return "(no error)"
+		return ""
 	}
 
-	var (
-		buf            bytes.Buffer
-		basic_child    bool
-		extended_child bool
-	)
+	return e.message
+}
 
-	switch e.err.(type) {
-	case *Error, List:
-		extended_child = true
-	default:
-		if e.err != nil {
-			basic_child = true
-		}
+// The stack captured when the error was made (without source context and
+// without the frames dropped by Rules)
+func (e *Error) Stack() Stack {
+	if e == nil {
+		return nil
 	}
 
-	fmt.Fprintf(&buf, "error: %s\n", e.message)
-
-	if basic_child {
-		fmt.Fprintf(&buf, "  message: %s\n", e.err)
+	if e.stack != nil {
+		return e.stack
 	}
 
-	if len(e.context) > 0 {
-		fmt.Fprintln(&buf, "  context:")
-
-		sort.Strings(e.context)
+	return e.callers.Stack().Filter()
+}
 
-		w := tabwriter.NewWriter(&buf, 0, 8, 1, ' ', 0)
+// Add some context to an error. The context is formatted as key=value
+// (or just key). Unlike With, AddContext never replaces existing context.
+func (e *Error) AddContext(format string, args ...interface{}) {
+	if e == nil {
+		return
+	}
 
-		for _, pair := range e.context {
-			parts := strings.SplitN(pair, "=", 2)
-			if len(parts) == 1 {
-				fmt.Fprintf(w, "    %s\t\n", parts[0])
-			} else {
-				fmt.Fprintf(w, "    %s\t= %s\n", parts[0], parts[1])
-			}
-		}
+	pair := fmt.Sprintf(format, args...)
+	parts := strings.SplitN(pair, "=", 2)
 
-		w.Flush()
+	if len(parts) == 1 {
+		e.context = append(e.context, field_t{key: parts[0]})
+	} else {
+		e.context = append(e.context, field_t{key: parts[0], value: parts[1], has_value: true})
 	}
+}
 
-	if len(e.stack) > 0 {
-		stack := e.stack.Limit(StackLimit).String()
-		stack = strings.Replace(stack, "\n", "\n    ", -1)
-		fmt.Fprintf(&buf, "  location:\n    %s\n", stack)
+// The error formatted according to DefaultMode
+func (e *Error) Error() string {
+	if e == nil {
+		return "(no error)"
 	}
 
-	if extended_child {
-		err := e.err.Error()
-		err = strings.TrimSpace(err)
-		err = strings.Replace(err, "\n", "\n  ", -1)
-		fmt.Fprintf(&buf, "  %s\n", err)
+	if DefaultMode == ShortMode {
+		return e.short()
 	}
 
-	return buf.String()
+	return e.full()
+}
+
+// the full report (see DefaultRenderer)
+func (e *Error) full() string {
+	return RenderString(DefaultRenderer, e)
 }
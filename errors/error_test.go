@@ -1,57 +1,170 @@
 package errors
 
 import (
-	"bytes"
-	"flag"
-	"io/ioutil"
-	"os/exec"
+	"io"
+	"os"
 	"strings"
 	"testing"
 )
 
-func TestAnnotateNested(t *testing.T) {
-	err1 := New("%s err", "foo")
-	err1.AddContext("hello=%s", "world")
+func inner_helper() *Error {
+	return New("inner")
+}
 
-	err2 := Annotate(err1, "%s err", "bar")
-	err2.AddContext("c=%d", 7)
-	err2.AddContext("a=%d", 42)
+func outer_helper(same_line bool) *Error {
+	if same_line {
+		return Annotate(inner_helper(), "outer")
+	}
 
-	diff(t, "new", err1.Error())
-	diff(t, "annotate", err2.Error())
+	err := inner_helper()
+	return Annotate(err, "outer")
 }
 
-var write_generated = flag.Bool("write-generated", false, "Write generated error messages")
+func TestAnnotateSharedFrames(t *testing.T) {
+	defer func(l int) { StackLimit = l }(StackLimit)
+	StackLimit = 10
+
+	tests := []struct {
+		same_line bool
+		marker    string
+		contexts  int
+	}{
+		{false, "... 1 frame shared with cause\n", 4},
+		{true, "errors.outer_helper() (0x", 3},
+	}
+
+	// (split to keep the source context from matching)
+	shared := "errors.TestAnnotateSharedFrames" + "() (0x"
+
+	for _, test := range tests {
+		s := outer_helper(test.same_line).Error()
 
-func diff(t *testing.T, golden, generated string) {
-	if write_generated != nil && *write_generated {
-		err := ioutil.WriteFile("testdata/"+golden+".txt", []byte(generated), 0644)
-		if err != nil {
-			panic(err)
+		if n := strings.Count(s, shared); n != 1 {
+			t.Errorf("expected the shared frame to be rendered once, got %d:\n%s", n, s)
+		}
+		if n := strings.Count(s, "> "); n != test.contexts {
+			t.Errorf("expected source context for each frame once, got %d:\n%s", n, s)
+		}
+		if i := strings.Index(s, "error: inner"); i < 0 || !strings.Contains(s[:i], test.marker) {
+			t.Errorf("expected the annotation to be marked:\n%s", s)
 		}
 	}
+}
+
+func TestUnwrap(t *testing.T) {
+	var (
+		root  = &os.PathError{Op: "open", Path: "/tmp/x", Err: io.EOF}
+		inner = Annotate(root, "failed to open")
+		list  List
+	)
+
+	list.Add(New("other"))
+	list.Add(List{inner})
+
+	outer := Annotate(list, "failed")
 
-	data, err := ioutil.ReadFile("testdata/" + golden + ".txt")
-	if err != nil {
-		panic(err)
+	if !Is(outer, io.EOF) {
+		t.Error("expected Is to find io.EOF through the List")
 	}
 
-	golden_str := string(data)
-	golden_str = strings.TrimRight(golden_str, "\n") // trim off trailing \n
+	var path_err *os.PathError
+	if !As(outer, &path_err) || path_err != root {
+		t.Error("expected As to find the *os.PathError")
+	}
+
+	if Cause(inner) != root {
+		t.Errorf("expected the cause to be the *os.PathError, got %v", Cause(inner))
+	}
+
+	if Cause(Annotate(List{inner}, "single")) != root {
+		t.Error("expected Cause to unwrap a List with a single error")
+	}
+
+	if Cause(outer) == root {
+		t.Error("expected Cause to stop at a List with multiple errors")
+	}
+}
+
+func TestFields(t *testing.T) {
+	err1 := New("inner").With("user", 7).With("retry", true)
+	err1.AddContext("hello=%s", "world")
+
+	err2 := Annotate(err1, "outer").With("user", 8)
+	err2.AddContext("flag")
 
-	if golden_str == generated {
-		return
+	fields := err2.Fields()
+	expected := map[string]interface{}{
+		"user":  8,
+		"retry": true,
+		"hello": "world",
+		"flag":  nil,
 	}
 
-	cmd := exec.Command("diff", "--ignore-space-change", "-U", "5", "--to-file", "-", "testdata/"+golden+".txt")
-	cmd.Stdin = bytes.NewReader([]byte(generated))
-	output, err := cmd.CombinedOutput()
-	if _, ok := err.(*exec.ExitError); ok && err.Error() == "exit status 1" {
-		t.Errorf("%s failed:\n%s\n", golden, output)
-		return
+	if len(fields) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, fields)
 	}
-	if err != nil {
-		t.Error(string(output))
-		panic(err)
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("%s: expected %v, got %v", key, value, fields[key])
+		}
+	}
+
+	if Fields(List{err2})["user"] != 8 {
+		t.Error("expected Fields to find the error in the List")
+	}
+
+	if !strings.Contains(err1.Error(), "    user  = 7\n") {
+		t.Errorf("expected the fields to be rendered:\n%s", err1)
+	}
+}
+
+func TestAddContext(t *testing.T) {
+	err := New("failed")
+	err.AddContext("path=%s", "a")
+	err.AddContext("path=%s", "b")
+	err.With("user", 7).With("user", 8)
+
+	report := err.Error()
+	for _, line := range []string{"    path = a\n", "    path = b\n", "    user = 8\n"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("expected %q in:\n%s", line, report)
+		}
+	}
+	if strings.Contains(report, "user = 7") {
+		t.Errorf("expected With to replace the user field:\n%s", report)
+	}
+	if err.Fields()["path"] != "b" {
+		t.Errorf("expected the last path to win, got %v", err.Fields()["path"])
+	}
+}
+
+func BenchmarkNew(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		New("hello %d", i)
+	}
+}
+
+func BenchmarkCaptureCallers(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CaptureCallers(0)
+	}
+}
+
+func BenchmarkCaptureStack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CaptureStack()
+	}
+}
+
+func BenchmarkError(b *testing.B) {
+	err := Annotate(New("foo"), "bar")
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = err.Error()
 	}
 }
@@ -0,0 +1,292 @@
+// Package errorstest has helpers for testing errors made with the errors
+// package.
+package errorstest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fd/go-util/errors"
+)
+
+// AssertGolden writes the golden files when the tests run with -update. The
+// flag is shared with the golden files of other helpers: when a package
+// imported before errorstest already defines -update that flag is used.
+func init() {
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "update the golden files")
+	}
+}
+
+func update() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+
+	ok, _ := strconv.ParseBool(f.Value.String())
+	return ok
+}
+
+// Frames of these packages depend on the Go version and are dropped by
+// Normalize.
+var VolatilePackages = []string{"runtime", "testing"}
+
+var (
+	pc_re      = regexp.MustCompile(`\(0x[0-9a-f]+\)`)
+	pointer_re = regexp.MustCompile(`0x[0-9a-f]{6,}`)
+)
+
+// Make a report from the Plain renderer stable across builds, machines and
+// Go versions:
+//
+//   - program counters and pointers become 0x0
+//   - the paths of GOROOT, the working directory and the temp directory
+//     become $GOROOT, $PWD and $TMPDIR
+//   - frames of the VolatilePackages are dropped
+//   - line numbers are counted from the start of the source context (0
+//     for frames without source context)
+//   - goroutines are numbered in order
+//
+// Reports which can't be parsed are only normalized textually.
+func Normalize(report string) string {
+	reports, err := errors.ParseReports(strings.NewReader(report))
+	if err == nil {
+		s := make([]string, len(reports))
+		for i, r := range reports {
+			normalize_report(r)
+			s[i] = r.String()
+		}
+		report = strings.Join(s, "\n")
+	}
+
+	report = pc_re.ReplaceAllString(report, "(0x0)")
+	report = pointer_re.ReplaceAllString(report, "0x0")
+
+	for _, dir := range []struct{ path, name string }{
+		{runtime.GOROOT(), "$GOROOT"},
+		{working_dir(), "$PWD"},
+		{os.TempDir(), "$TMPDIR"},
+	} {
+		if dir.path != "" && dir.path != "/" {
+			report = strings.Replace(report, dir.path, dir.name, -1)
+		}
+	}
+
+	return report
+}
+
+func normalize_report(r *errors.Report) {
+	r.Stack = normalize_stack(r.Stack)
+
+	for i := range r.Goroutines {
+		g := &r.Goroutines[i]
+		g.ID = i + 1
+		g.Stack = normalize_stack(g.Stack)
+		g.CreatedBy.Line = 0
+	}
+
+	for _, c := range r.Causes {
+		normalize_report(c)
+	}
+}
+
+func normalize_stack(s errors.Stack) errors.Stack {
+	o := make(errors.Stack, 0, len(s))
+
+	for _, f := range s {
+		if volatile(f.Package) {
+			continue
+		}
+
+		f.PC = 0
+		f.Line = 0
+		if f.HasContext {
+			f.Line = len(f.PreContext) + 1
+		}
+
+		o = append(o, f)
+	}
+
+	return o
+}
+
+// Package is only the last element of the import path in parsed reports
+func volatile(pkg string) bool {
+	for _, v := range VolatilePackages {
+		if pkg == v || pkg == path.Base(v) || strings.HasPrefix(pkg, v+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func working_dir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	return dir
+}
+
+// Compare the normalized full report of err with the golden file at path.
+// Run the tests with -update to write the golden files.
+func AssertGolden(t testing.TB, err error, path string) {
+	t.Helper()
+
+	generated := Normalize(errors.RenderString(errors.Plain, err))
+
+	if update() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(generated), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	data, read_err := ioutil.ReadFile(path)
+	if read_err != nil {
+		t.Fatalf("%s (run the tests with -update to write it)", read_err)
+	}
+
+	if string(data) == generated {
+		return
+	}
+
+	t.Errorf("%s doesn't match:\n%s", path, diff(string(data), generated))
+}
+
+// A line diff of the golden file and the generated report (like diff -u
+// without the hunk headers)
+func diff(expected, got string) string {
+	var (
+		a = strings.Split(expected, "\n")
+		b = strings.Split(got, "\n")
+	)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	return context_lines(lines, 5)
+}
+
+// Keep n unchanged lines around the changes and replace the others with
+// "@@ ... @@"
+func context_lines(lines []string, n int) string {
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+		for j := i - n; j <= i+n; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	var (
+		o       bytes.Buffer
+		skipped = 0
+	)
+
+	for i, line := range lines {
+		if !keep[i] {
+			skipped++
+			continue
+		}
+		if skipped > 0 {
+			fmt.Fprintf(&o, "@@ %d unchanged lines @@\n", skipped)
+			skipped = 0
+		}
+		o.WriteString(line)
+		o.WriteByte('\n')
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&o, "@@ %d unchanged lines @@\n", skipped)
+	}
+
+	return o.String()
+}
+
+// Check the kind of err (see errors.KindOf).
+func AssertKind(t testing.TB, err error, kind errors.Kind) {
+	t.Helper()
+
+	if k := errors.KindOf(err); k != kind {
+		t.Errorf("expected an error of kind %s, got %s: %v", kind, k, err)
+	}
+}
+
+// Check a context field of err (see errors.Fields). Values added with
+// AddContext are strings.
+func AssertContext(t testing.TB, err error, key string, value interface{}) {
+	t.Helper()
+
+	fields := errors.Fields(err)
+
+	v, found := fields[key]
+	if !found {
+		t.Errorf("expected the context field %q, got %v", key, fields)
+		return
+	}
+
+	if !reflect.DeepEqual(v, value) {
+		t.Errorf("expected %s=%#v, got %#v", key, value, v)
+	}
+}
+
+// Check that target is in the chain of err (see errors.Is).
+func AssertIs(t testing.TB, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Errorf("expected %v in the chain of %v", target, err)
+	}
+}
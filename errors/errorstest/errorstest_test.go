@@ -0,0 +1,87 @@
+package errorstest
+
+import (
+	"flag"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/fd/go-util/errors"
+)
+
+func TestNormalize(t *testing.T) {
+	report := "" +
+		"error: open " + runtime.GOROOT() + "/VERSION: object 0xc000123456 is gone\n" +
+		"  location:\n" +
+		"    example.com/app/main.go:42 main.main() (0x4a1b2c)\n" +
+		"      41 func main() {\n" +
+		"    > 42     run()\n" +
+		"      43 }\n" +
+		"    runtime/proc.go:283 runtime.main() (0x43f00d)\n" +
+		"  goroutines:\n" +
+		"    goroutine 17 [running]:\n" +
+		"      example.com/app/main.go:42 main.main()\n" +
+		"      testing/testing.go:1792 testing.tRunner()\n"
+
+	expected := "" +
+		"error: open $GOROOT/VERSION: object 0x0 is gone\n" +
+		"  location:\n" +
+		"    example.com/app/main.go:2 main.main() (0x0)\n" +
+		"      1 func main() {\n" +
+		"    > 2     run()\n" +
+		"      3 }\n" +
+		"  goroutines:\n" +
+		"    goroutine 1 [running]:\n" +
+		"      example.com/app/main.go:0 main.main()\n"
+
+	if s := Normalize(report); s != expected {
+		t.Errorf("unexpected report:\n%s", s)
+	}
+}
+
+func TestAsserts(t *testing.T) {
+	err := errors.Annotate(errors.NotFound("user 7"), "load").With("id", 7)
+	err.AddContext("source=db")
+
+	AssertKind(t, err, errors.KindNotFound)
+	AssertContext(t, err, "id", 7)
+	AssertContext(t, err, "source", "db")
+	AssertIs(t, errors.Annotate(io.EOF, "read"), io.EOF)
+}
+
+func TestDiff(t *testing.T) {
+	expected := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	got := "a\nb\nc\nd\ne\nf\nG\nh\ni\nj\nk\nl\nm\n"
+
+	d := "" +
+		"@@ 1 unchanged lines @@\n" +
+		" b\n c\n d\n e\n f\n" +
+		"-g\n" +
+		"+G\n" +
+		" h\n i\n j\n k\n l\n" +
+		"@@ 2 unchanged lines @@\n"
+
+	if s := diff(expected, got); s != d {
+		t.Errorf("unexpected diff:\n%s", s)
+	}
+}
+
+func TestUpdateFlag(t *testing.T) {
+	f := flag.Lookup("update")
+	if f == nil {
+		t.Fatal("expected the -update flag")
+	}
+
+	old := f.Value.String()
+	defer f.Value.Set(old)
+
+	f.Value.Set("true")
+	if !update() {
+		t.Error("expected update() with -update=true")
+	}
+
+	f.Value.Set("false")
+	if update() {
+		t.Error("expected !update() with -update=false")
+	}
+}
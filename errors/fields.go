@@ -0,0 +1,82 @@
+package errors
+
+import (
+	"sort"
+)
+
+type field_t struct {
+	key       string
+	value     interface{}
+	has_value bool
+}
+
+// Add a typed context field to the error. A field with the same key
+// replaces the previous value.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e == nil {
+		return nil
+	}
+
+	e.set_field(field_t{key: key, value: value, has_value: true})
+	return e
+}
+
+// The context fields of the error merged with those of its causes. Fields
+// of outer errors override the fields of the errors they annotate. Keys
+// without a value (see AddContext) map to nil.
+func (e *Error) Fields() map[string]interface{} {
+	if e == nil {
+		return nil
+	}
+
+	var chain []*Error
+	for c := e; c != nil; {
+		chain = append(chain, c)
+		c, _ = c.err.(*Error)
+	}
+
+	fields := map[string]interface{}{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, f := range chain[i].context {
+			fields[f.key] = f.value
+		}
+	}
+
+	return fields
+}
+
+// The context fields of the first *Error in the chain of err (nil when
+// there is none).
+func Fields(err error) map[string]interface{} {
+	var e *Error
+
+	if !As(err, &e) {
+		return nil
+	}
+
+	return e.Fields()
+}
+
+func (e *Error) set_field(f field_t) {
+	for i, g := range e.context {
+		if g.key == f.key {
+			e.context[i] = f
+			return
+		}
+	}
+
+	e.context = append(e.context, f)
+}
+
+func sorted_fields(fields []field_t) []field_t {
+	o := make([]field_t, len(fields))
+	copy(o, fields)
+	sort.Stable(field_sorter(o))
+	return o
+}
+
+type field_sorter []field_t
+
+func (s field_sorter) Len() int           { return len(s) }
+func (s field_sorter) Less(i, j int) bool { return s[i].key < s[j].key }
+func (s field_sorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
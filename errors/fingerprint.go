@@ -0,0 +1,130 @@
+package errors
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Include line numbers in fingerprints. Without them a fingerprint
+// survives unrelated edits to the files in the stack.
+var FingerprintLines = false
+
+// A stable identifier for the kind of failure err represents. Errors made
+// by the same call site (with the same message template, ignoring the
+// formatted arguments) through the same in-app frames, with the same
+// causes, have the same fingerprint (also across processes).
+func Fingerprint(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	h := sha1.New()
+	fingerprint(h, err)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func fingerprint(h hash.Hash, err error) {
+	switch e := err.(type) {
+
+	case *Error:
+		if e == nil {
+			io.WriteString(h, "nil\x00")
+			return
+		}
+
+		format := e.format
+		if format == "" {
+			format = e.message
+		}
+
+		fmt.Fprintf(h, "error\x00%s\x00%s\x00", format, e.kind)
+
+		for _, f := range e.Stack() {
+			if !f.InApp {
+				continue
+			}
+
+			fmt.Fprintf(h, "%s\x00%s\x00%s\x00", f.Package, f.FuncName(), f.Filename)
+			if FingerprintLines {
+				fmt.Fprintf(h, "%d\x00", f.Line)
+			}
+		}
+
+		if e.err != nil {
+			fingerprint(h, e.err)
+		}
+
+	case List:
+		fmt.Fprintf(h, "list\x00%d\x00", len(e))
+		for _, err := range e {
+			fingerprint(h, err)
+		}
+
+	case *RemoteError:
+		fmt.Fprintf(h, "%s\x00%s\x00", e.Type, e.Message)
+
+	case interface{ Unwrap() error }:
+		// the message of a wrapper usually contains its cause; only the
+		// text the wrapper adds is part of the fingerprint.
+		cause := e.Unwrap()
+		message := err.Error()
+		if cause != nil {
+			// %v is what fmt.Errorf writes for a %w verb
+			message = strings.Replace(message, fmt.Sprintf("%v", cause), "%w", -1)
+		}
+
+		fmt.Fprintf(h, "%T\x00%s\x00", err, message)
+		if cause != nil {
+			fingerprint(h, cause)
+		}
+
+	default:
+		fmt.Fprintf(h, "%T\x00", err)
+		io.WriteString(h, err.Error())
+		io.WriteString(h, "\x00")
+
+	}
+}
+
+// Collapse errors with the same Fingerprint into the first one. Errors which
+// occur more than once are annotated with "repeated N times" and a count
+// field.
+func (l List) Dedup() List {
+	var (
+		o      = make(List, 0, len(l))
+		counts = make([]int, 0, len(l))
+		index  = make(map[string]int, len(l))
+	)
+
+	for _, err := range l {
+		if err == nil {
+			continue
+		}
+
+		key := Fingerprint(err)
+		if i, found := index[key]; found {
+			counts[i]++
+			continue
+		}
+
+		index[key] = len(o)
+		o = append(o, err)
+		counts = append(counts, 1)
+	}
+
+	for i, n := range counts {
+		if n == 1 {
+			continue
+		}
+
+		e := new_error(1, o[i], "repeated %d times", []interface{}{n})
+		e.fatal = IsFatal(o[i])
+		o[i] = e.With("count", n)
+	}
+
+	return o
+}
@@ -0,0 +1,66 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	make_err := func(id int) error {
+		return Annotate(NotFound("user %d", id), "load %d", id)
+	}
+
+	a, b := make_err(1), make_err(2)
+	if Fingerprint(a) == "" || Fingerprint(a) != Fingerprint(b) {
+		t.Error("expected the arguments to be ignored")
+	}
+	if Fingerprint(a) == Fingerprint(Annotate(NotFound("user %d", 1), "load %d", 1)) {
+		t.Error("expected a different call site to change the fingerprint")
+	}
+	if Fingerprint(a) == Fingerprint(Annotate(a, "outer")) {
+		t.Error("expected the cause chain to be included")
+	}
+	if Fingerprint(Annotate(io.EOF, "x")) == Fingerprint(Annotate(io.ErrUnexpectedEOF, "x")) {
+		t.Error("expected foreign causes to be distinguished")
+	}
+	if Fingerprint(fmt.Errorf("read: %w", a)) == Fingerprint(fmt.Errorf("write: %w", a)) {
+		t.Error("expected the message of a wrapper to be included")
+	}
+	if Fingerprint(fmt.Errorf("read: %w", a)) != Fingerprint(fmt.Errorf("read: %w", b)) {
+		t.Error("expected the message of the cause to be ignored in a wrapper")
+	}
+	if Fingerprint((*Error)(nil)) == "" {
+		t.Error("expected a nil *Error to have a fingerprint")
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Fingerprint(decoded) != Fingerprint(a) {
+		t.Error("expected decoded errors to keep their fingerprint")
+	}
+
+	var l List
+	for i := 0; i < 3; i++ {
+		l.Add(make_err(i))
+	}
+	l.Add(io.EOF)
+
+	l = l.Dedup()
+	if len(l) != 2 || l[1] != io.EOF {
+		t.Fatalf("expected 2 errors, got %v", l)
+	}
+	if e := l[0].(*Error); e.Fields()["count"] != 3 || KindOf(e) != KindNotFound {
+		t.Errorf("expected the repeats to be counted, got %#v", e)
+	}
+	if s := l.short(); s != "[repeated 3 times: load 0: user 0; EOF]" {
+		t.Errorf("unexpected message: %s", s)
+	}
+}
@@ -0,0 +1,149 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// How Error() renders errors
+type Mode uint8
+
+const (
+	// The multi-line report with context, stacks and source context
+	FullMode Mode = iota
+	// A single line: "outer: inner: root"
+	ShortMode
+)
+
+// The Mode used by (*Error).Error() and List.Error()
+var DefaultMode = FullMode
+
+// Format implements fmt.Formatter:
+//
+//	%s, %v  a single line: "outer: inner: root"
+//	%+v     the full report (with context, stacks and source context)
+//	%#v     a debug dump of the error chain
+//	%q      the single line, quoted
+func (e *Error) Format(s fmt.State, verb rune) {
+	format(s, verb, e)
+}
+
+// Format implements fmt.Formatter (see (*Error).Format)
+func (l List) Format(s fmt.State, verb rune) {
+	format(s, verb, l)
+}
+
+func format(s fmt.State, verb rune, err error) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, full_string(err))
+		} else if s.Flag('#') {
+			io.WriteString(s, debug_string(err))
+		} else {
+			io.WriteString(s, short_string(err))
+		}
+	case 's':
+		io.WriteString(s, short_string(err))
+	case 'q':
+		fmt.Fprintf(s, "%q", short_string(err))
+	default:
+		fmt.Fprintf(s, "%%!%c(%s)", verb, short_string(err))
+	}
+}
+
+// the single line rendering of the chain
+func (e *Error) short() string {
+	if e == nil {
+		return "(no error)"
+	}
+
+	if e.err == nil {
+		return e.message
+	}
+
+	return e.message + ": " + short_string(e.err)
+}
+
+func short_string(err error) string {
+	switch e := err.(type) {
+	case *Error:
+		return e.short()
+	case List:
+		return e.short()
+	default:
+		return err.Error()
+	}
+}
+
+func full_string(err error) string {
+	switch e := err.(type) {
+	case *Error:
+		if e == nil {
+			return "(no error)"
+		}
+		return e.full()
+	case List:
+		return e.full()
+	default:
+		return err.Error()
+	}
+}
+
+func debug_string(err error) string {
+	switch e := err.(type) {
+
+	case *Error:
+		if e == nil {
+			return "(*errors.Error)(nil)"
+		}
+
+		parts := []string{
+			fmt.Sprintf("message:%q", e.message),
+			fmt.Sprintf("fatal:%t", e.fatal),
+		}
+
+		if e.public != "" {
+			parts = append(parts, fmt.Sprintf("public:%q", e.public))
+		}
+
+		if e.kind != KindUnknown {
+			parts = append(parts, "kind:"+e.kind.String())
+		}
+
+		if len(e.context) > 0 {
+			fields := make([]string, len(e.context))
+			for i, f := range sorted_fields(e.context) {
+				if f.has_value {
+					fields[i] = fmt.Sprintf("%s:%#v", f.key, redact(f.key, f.value))
+				} else {
+					fields[i] = f.key
+				}
+			}
+			parts = append(parts, "context:{"+strings.Join(fields, ", ")+"}")
+		}
+
+		if stack := e.Stack(); len(stack) > 0 {
+			f := stack[0]
+			parts = append(parts, fmt.Sprintf("stack:[%d frames, %s:%d]", len(stack), f.Location(), f.Line))
+		}
+
+		if e.err != nil {
+			parts = append(parts, "err:"+debug_string(e.err))
+		}
+
+		return "&errors.Error{" + strings.Join(parts, ", ") + "}"
+
+	case List:
+		s := make([]string, len(e))
+		for i, err := range e {
+			s[i] = debug_string(err)
+		}
+		return "errors.List{" + strings.Join(s, ", ") + "}"
+
+	default:
+		return fmt.Sprintf("%#v", err)
+
+	}
+}
@@ -0,0 +1,37 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	err1 := New("root").With("a", 1)
+	err2 := Annotate(List{err1, io.EOF}, "inner")
+	err3 := Annotate(err2, "outer")
+
+	if s := fmt.Sprintf("%s", err3); s != "outer: inner: [root; EOF]" {
+		t.Errorf("unexpected %%s: %q", s)
+	}
+	if s := fmt.Sprintf("%v", err3); s != "outer: inner: [root; EOF]" {
+		t.Errorf("unexpected %%v: %q", s)
+	}
+	if s := fmt.Sprintf("%+v", err3); s != err3.Error() {
+		t.Errorf("expected %%+v to render the full report:\n%s", s)
+	}
+	if s := fmt.Sprintf("%#v", err1); !strings.HasPrefix(s, `&errors.Error{message:"root", fatal:true, context:{a:1}, stack:[`) {
+		t.Errorf("unexpected %%#v: %s", s)
+	}
+
+	DefaultMode = ShortMode
+	defer func() { DefaultMode = FullMode }()
+
+	if s := err3.Error(); s != "outer: inner: [root; EOF]" {
+		t.Errorf("unexpected short Error(): %q", s)
+	}
+	if s := fmt.Sprintf("%+v", err3); !strings.Contains(s, "location:") {
+		t.Errorf("expected %%+v to render the full report:\n%s", s)
+	}
+}
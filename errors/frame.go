@@ -0,0 +1,193 @@
+package errors
+
+import (
+	"path"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// The path of the source file including the module path, like
+// github.com/fd/go-util/errors/error.go or testing/testing.go
+func (f *StackFrame) Location() string {
+	if f.Module == "" {
+		return f.Filename
+	}
+
+	return f.Module + "/" + f.Filename
+}
+
+// The name of the function qualified with its receiver, like (*Error).Error
+func (f *StackFrame) FuncName() string {
+	if f.Receiver == "" {
+		return f.Function
+	}
+
+	return "(" + f.Receiver + ")." + f.Function
+}
+
+// Split a symbol name like github.com/fd/go-util/errors.(*Error).Error into
+// the package, receiver and function name.
+func function(fname string, frame *StackFrame) {
+	frame.Package, frame.Receiver, frame.Function = parse_function(fname)
+}
+
+func parse_function(fname string) (pkg, receiver, name string) {
+	if fname == "" {
+		return "", "", ""
+	}
+
+	// the package path ends at the first dot after the last slash. Type
+	// arguments of generic functions ([...]) are ignored as they may contain
+	// slashes and dots.
+	head := fname
+	if i := strings.IndexByte(head, '['); i >= 0 {
+		head = head[:i]
+	}
+
+	slash_idx := strings.LastIndexByte(head, '/')
+	dot_idx := strings.IndexByte(head[slash_idx+1:], '.')
+	if dot_idx < 0 {
+		return "", "", fname
+	}
+
+	pkg = fname[:slash_idx+1+dot_idx]
+	name = fname[slash_idx+dot_idx+2:]
+
+	// dots in the last element of the package path are escaped by the linker
+	pkg = strings.Replace(pkg, "%2e", ".", -1)
+
+	// pointer receiver: (*T).M
+	if strings.HasPrefix(name, "(") {
+		if end := strings.Index(name, ")."); end > 0 {
+			return pkg, name[1:end], name[end+2:]
+		}
+		return pkg, "", name
+	}
+
+	// value receiver: T.M (but not closures like F.func1 or F.1)
+	if i := index_outside_brackets(name, '.'); i > 0 {
+		next := name[i+1:]
+		if j := index_outside_brackets(next, '.'); j >= 0 {
+			next = next[:j]
+		}
+
+		if !is_closure(next) {
+			return pkg, name[:i], name[i+1:]
+		}
+	}
+
+	return pkg, "", name
+}
+
+func index_outside_brackets(s string, c byte) int {
+	depth := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case c:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// closures are named func1, func2, ... (or 1, 2, ... when nested) and go
+// statements and defers may be wrapped in gowrap1 and deferwrap1.
+func is_closure(name string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if strings.HasPrefix(name, prefix) {
+			name = name[len(prefix):]
+			break
+		}
+	}
+
+	if name == "" {
+		return true
+	}
+
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Set the Module and the module-relative Filename of the frame.
+func filename(frame *StackFrame) {
+	main, modules := build_modules()
+	frame.Module, frame.Filename = module_filename(frame.Package, frame.Filepath, main, modules)
+}
+
+func module_filename(pkg, file, main string, modules []string) (module, name string) {
+	if !strings.Contains(file, "/") {
+		// like <autogenerated>
+		return "", file
+	}
+
+	base := path.Base(file)
+
+	if pkg == "" {
+		return "", base
+	}
+
+	// the main package has no import path
+	if pkg == "main" {
+		if main != "" {
+			return main, base
+		}
+		return "", path.Join(pkg, base)
+	}
+
+	// external test packages live in the directory of the package they test
+	dir := strings.TrimSuffix(pkg, "_test")
+
+	for _, m := range modules {
+		if len(m) > len(module) && (dir == m || strings.HasPrefix(dir, m+"/")) {
+			module = m
+		}
+	}
+
+	if module == "" {
+		return "", path.Join(dir, base)
+	}
+
+	return module, path.Join(strings.TrimPrefix(dir[len(module):], "/"), base)
+}
+
+var (
+	build_modules_once sync.Once
+	build_main_module  string
+	build_module_paths []string
+)
+
+// the path of the main module and the paths of all modules (including
+// the main module)
+func build_modules() (string, []string) {
+	build_modules_once.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		build_main_module = info.Main.Path
+		if info.Main.Path != "" {
+			build_module_paths = append(build_module_paths, info.Main.Path)
+		}
+
+		for _, dep := range info.Deps {
+			build_module_paths = append(build_module_paths, dep.Path)
+		}
+	})
+
+	return build_main_module, build_module_paths
+}
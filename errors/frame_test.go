@@ -0,0 +1,173 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFunction(t *testing.T) {
+	tests := []struct {
+		symbol   string
+		pkg      string
+		receiver string
+		name     string
+	}{
+		{"main.main", "main", "", "main"},
+		{"github.com/fd/go-util/errors.New", "github.com/fd/go-util/errors", "", "New"},
+		{"github.com/fd/go-util/errors.(*Error).Error", "github.com/fd/go-util/errors", "*Error", "Error"},
+		{"github.com/fd/go-util/errors.List.Error", "github.com/fd/go-util/errors", "List", "Error"},
+		{"github.com/fd/go-util/errors.Guard.func1", "github.com/fd/go-util/errors", "", "Guard.func1"},
+		{"github.com/fd/go-util/errors.Guard.func1.2", "github.com/fd/go-util/errors", "", "Guard.func1.2"},
+		{"github.com/fd/go-util/errors.(*Error).Error.func1", "github.com/fd/go-util/errors", "*Error", "Error.func1"},
+		{"github.com/fd/go-util/errors.List.Error.func1", "github.com/fd/go-util/errors", "List", "Error.func1"},
+		{"github.com/fd/go-util/errors.init.0", "github.com/fd/go-util/errors", "", "init.0"},
+		{"github.com/fd/go-util/errors.glob..func1", "github.com/fd/go-util/errors", "", "glob..func1"},
+		{"example.com/x.Map[...]", "example.com/x", "", "Map[...]"},
+		{"example.com/x.Map[...].func1", "example.com/x", "", "Map[...].func1"},
+		{"example.com/x.(*Set[...]).Add", "example.com/x", "*Set[...]", "Add"},
+		{"example.com/x.Pair[...].First", "example.com/x", "Pair[...]", "First"},
+		{"example.com/x.F[go.shape.string]", "example.com/x", "", "F[go.shape.string]"},
+		{"gopkg.in/yaml%2ev2.Unmarshal", "gopkg.in/yaml.v2", "", "Unmarshal"},
+		{"testing.tRunner", "testing", "", "tRunner"},
+		{"testing.tRunner.gowrap1", "testing", "", "tRunner.gowrap1"},
+		{"runtime.goexit", "runtime", "", "goexit"},
+		{"net/http.HandlerFunc.ServeHTTP", "net/http", "HandlerFunc", "ServeHTTP"},
+		{"", "", "", ""},
+	}
+
+	for _, test := range tests {
+		pkg, receiver, name := parse_function(test.symbol)
+		if pkg != test.pkg || receiver != test.receiver || name != test.name {
+			t.Errorf("%q: expected (%q, %q, %q), got (%q, %q, %q)",
+				test.symbol, test.pkg, test.receiver, test.name, pkg, receiver, name)
+		}
+	}
+}
+
+func TestModuleFilename(t *testing.T) {
+	modules := []string{"github.com/fd/go-util", "github.com/fd/go-util/sub", "golang.org/x/net"}
+
+	tests := []struct {
+		pkg    string
+		file   string
+		module string
+		name   string
+	}{
+		{"github.com/fd/go-util/errors", "/src/go-util/errors/error_test.go", "github.com/fd/go-util", "errors/error_test.go"},
+		{"github.com/fd/go-util/errors_test", "/src/go-util/errors/x_test.go", "github.com/fd/go-util", "errors/x_test.go"},
+		{"github.com/fd/go-util", "/src/go-util/doc.go", "github.com/fd/go-util", "doc.go"},
+		{"github.com/fd/go-util/sub/pkg", "/mod/sub/pkg/a.go", "github.com/fd/go-util/sub", "pkg/a.go"},
+		{"golang.org/x/net/http2", "/mod/golang.org/x/net@v0.1.0/http2/server.go", "golang.org/x/net", "http2/server.go"},
+		{"testing", "/usr/local/go/src/testing/testing.go", "", "testing/testing.go"},
+		{"runtime", "/usr/local/go/src/runtime/asm_amd64.s", "", "runtime/asm_amd64.s"},
+		{"main", "/src/go-util/cmd/tool/main.go", "github.com/fd/go-util", "main.go"},
+		{"example.com/other", "/src/other/a.go", "", "example.com/other/a.go"},
+		{"runtime", "<autogenerated>", "", "<autogenerated>"},
+	}
+
+	for _, test := range tests {
+		module, name := module_filename(test.pkg, test.file, modules[0], modules)
+		if module != test.module || name != test.name {
+			t.Errorf("%s %s: expected (%q, %q), got (%q, %q)", test.pkg, test.file, test.module, test.name, module, name)
+		}
+	}
+}
+
+type frame_receiver_t struct{}
+
+func (frame_receiver_t) value() Stack {
+	return CaptureCallers(0).Stack()
+}
+
+func (*frame_receiver_t) pointer() Stack {
+	return func() Stack { return CaptureCallers(0).Stack() }()
+}
+
+func TestCapturedFrames(t *testing.T) {
+	var r frame_receiver_t
+
+	tests := []struct {
+		stack    Stack
+		receiver string
+		name     string
+	}{
+		{r.value(), "frame_receiver_t", "value"},
+		{r.pointer(), "*frame_receiver_t", "pointer.func1"},
+	}
+
+	for _, test := range tests {
+		f := test.stack[0]
+
+		if f.Package != "github.com/fd/go-util/errors" || f.Receiver != test.receiver || f.Function != test.name {
+			t.Errorf("unexpected frame: %s %s %s", f.Package, f.Receiver, f.Function)
+		}
+		// the module is only known when the build has a main module
+		if !strings.HasSuffix(f.Location(), "errors/frame_test.go") {
+			t.Errorf("unexpected location: %s", f.Location())
+		}
+		if main, _ := build_modules(); main != "" && f.Module != "github.com/fd/go-util" {
+			t.Errorf("unexpected module: %s", f.Module)
+		}
+	}
+
+	stack := inlined_capture().Stack()
+	if stack[0].Function != "inlined_capture" || stack[1].Function != "TestCapturedFrames" {
+		t.Fatalf("expected the inlined call to have its own frame, got %s %s", stack[0].Function, stack[1].Function)
+	}
+	if !stack[0].Inlined {
+		t.Skip("inlined_capture was not inlined")
+	}
+}
+
+func inlined_capture() Callers {
+	return CaptureCallers(0)
+}
+
+func TestStackRules(t *testing.T) {
+	stack := CaptureCallers(0).Stack()
+	if !stack[0].InApp {
+		t.Error("expected the test frame to be in-app")
+	}
+	for _, f := range stack[1:] {
+		if f.InApp {
+			t.Errorf("expected %s.%s not to be in-app", f.Package, f.FuncName())
+		}
+	}
+
+	err := Guard(func() error { panic("boom") })
+	for _, f := range err.(*Error).Stack() {
+		if f.Package == "runtime" || f.Package == "testing" || (f.Package == self_package && !strings.HasSuffix(f.Location(), "errors/frame_test.go")) {
+			t.Errorf("expected %s.%s to be dropped", f.Package, f.FuncName())
+		}
+	}
+	if f := err.(*Error).Stack()[0]; f.Function != "TestStackRules.func1" {
+		t.Errorf("expected the stack to start at the panic, got %s", f.FuncName())
+	}
+
+	defer func(r StackRules) { Rules = r }(Rules)
+	Rules = StackRules{InApp: []string{"testing"}, CollapseLibrary: true}
+
+	stack = CaptureCallers(0).Stack()
+	if stack[0].InApp || !stack[1].InApp {
+		t.Error("expected the InApp rules to be used")
+	}
+
+	stack = Stack{
+		{Package: "example.com/app", Function: "main", InApp: true},
+		{Package: "example.com/lib", Function: "a"},
+		{Package: "example.com/lib", Function: "b"},
+		{Package: "net/http", Function: "c"},
+		{Package: "example.com/app", Function: "handler", InApp: true},
+		{Package: "net/http", Function: "d"},
+	}
+
+	expected := "" +
+		"app.main() (0x0)\n" +
+		"... 3 library frames (example.com/lib, net/http)\n" +
+		"app.handler() (0x0)\n" +
+		"http.d() (0x0)"
+
+	if s := strings.Replace(stack.String(), ":0 ", "", -1); s != expected {
+		t.Errorf("expected the library frames to be collapsed:\n%s", s)
+	}
+}
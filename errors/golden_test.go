@@ -0,0 +1,24 @@
+package errors_test
+
+import (
+	"testing"
+
+	"github.com/fd/go-util/errors"
+	"github.com/fd/go-util/errors/errorstest"
+)
+
+func TestAnnotateNested(t *testing.T) {
+	err1 := errors.New("%s err", "foo")
+	err1.AddContext("hello=%s", "world")
+
+	err2 := errors.Annotate(err1, "%s err", "bar")
+	err2.AddContext("c=%d", 7)
+	err2.AddContext("a=%d", 42)
+
+	errorstest.AssertGolden(t, err1, "testdata/new.txt")
+	errorstest.AssertGolden(t, err2, "testdata/annotate.txt")
+
+	errorstest.AssertContext(t, err2, "hello", "world")
+	errorstest.AssertContext(t, err2, "a", "42")
+	errorstest.AssertKind(t, err2, errors.KindUnknown)
+}
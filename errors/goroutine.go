@@ -0,0 +1,272 @@
+package errors
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Attach a dump of all goroutines to the errors made by NewFromPanic
+// (useful for deadlocks). Stopping the world to take the dump is expensive.
+var PanicGoroutines = false
+
+// A goroutine from a dump made by CaptureGoroutines
+type Goroutine struct {
+	ID        int
+	State     string        // like running or chan receive
+	Wait      time.Duration // how long the goroutine has been blocked (in minutes)
+	Locked    bool          // locked to its OS thread
+	Stack     Stack         // the frames have no PC
+	CreatedBy StackFrame    // the go statement which started the goroutine
+}
+
+// Capture the stacks of all goroutines. The calling goroutine comes first.
+func CaptureGoroutines() []Goroutine {
+	buf := make([]byte, 64<<10)
+
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return parse_goroutines(buf[:n])
+		}
+
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// Attach a dump of all goroutines to the error.
+func (e *Error) WithGoroutines() *Error {
+	if e == nil {
+		return nil
+	}
+
+	e.goroutines = CaptureGoroutines()
+	return e
+}
+
+// The goroutines attached with WithGoroutines (or PanicGoroutines)
+func (e *Error) Goroutines() []Goroutine {
+	if e == nil {
+		return nil
+	}
+
+	return e.goroutines
+}
+
+// Parse the output of runtime.Stack(buf, true) (or of a crashing program).
+func parse_goroutines(data []byte) []Goroutine {
+	var (
+		goroutines []Goroutine
+		g          *Goroutine
+		symbol     string
+		created_by bool
+		s          = bufio.NewScanner(bytes.NewReader(data))
+	)
+
+	s.Buffer(nil, 1<<20)
+
+	for s.Scan() {
+		line := s.Text()
+
+		switch {
+
+		case strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, "]:"):
+			goroutines = append(goroutines, parse_goroutine_header(line))
+			g = &goroutines[len(goroutines)-1]
+			symbol = ""
+
+		case g == nil || line == "":
+			g = nil
+
+		case strings.HasPrefix(line, "\t"):
+			if symbol == "" {
+				continue
+			}
+
+			frame := StackFrame{}
+			frame.Filepath, frame.Line = parse_goroutine_location(line[1:])
+			function(symbol, &frame)
+			if frame.Package == "" {
+				// runtime functions like panic are not qualified
+				frame.Package = "runtime"
+			}
+			filename(&frame)
+			frame.InApp = Rules.in_app(&frame)
+
+			if created_by {
+				g.CreatedBy = frame
+			} else {
+				g.Stack = append(g.Stack, frame)
+			}
+
+			symbol = ""
+
+		case strings.HasPrefix(line, "created by "):
+			symbol = strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(symbol, " in goroutine "); i >= 0 {
+				symbol = symbol[:i]
+			}
+			created_by = true
+
+		default:
+			// a call like pkg.(*T).f(0xc000010000, ...); other lines (like
+			// ...additional frames elided...) have no arguments
+			symbol = ""
+			created_by = false
+			if strings.HasSuffix(line, ")") {
+				if i := strings.LastIndexByte(line, '('); i > 0 {
+					symbol = line[:i]
+				}
+			}
+
+		}
+	}
+
+	return goroutines
+}
+
+// goroutine 18 [chan receive, 2 minutes, locked to thread]:
+func parse_goroutine_header(line string) Goroutine {
+	var g Goroutine
+
+	fields := strings.Fields(line)
+	g.ID, _ = strconv.Atoi(fields[1])
+
+	attrs := line[strings.IndexByte(line, '[')+1 : len(line)-2]
+	for i, attr := range strings.Split(attrs, ", ") {
+		switch {
+		case i == 0:
+			g.State = attr
+		case attr == "locked to thread":
+			g.Locked = true
+		case strings.HasSuffix(attr, " minutes"):
+			// the lower bound of a range (3-7 minutes) in a rendered group
+			attr = strings.TrimSuffix(attr, " minutes")
+			if i := strings.IndexByte(attr, '-'); i >= 0 {
+				attr = attr[:i]
+			}
+			n, _ := strconv.Atoi(attr)
+			g.Wait = time.Duration(n) * time.Minute
+		}
+	}
+
+	return g
+}
+
+// /path/to/file.go:42 +0x3d
+func parse_goroutine_location(line string) (file string, n int) {
+	if i := strings.LastIndex(line, " +0x"); i >= 0 {
+		line = line[:i]
+	}
+
+	i := strings.LastIndexByte(line, ':')
+	if i < 0 {
+		return line, 0
+	}
+
+	n, _ = strconv.Atoi(line[i+1:])
+	return line[:i], n
+}
+
+// Render the goroutines with identical states and stacks once. The header
+// of a group shows the range of the wait times of its goroutines.
+func write_goroutines(w *bytes.Buffer, goroutines []Goroutine) {
+	type group_t struct {
+		ids      []string
+		g        *Goroutine
+		min, max time.Duration
+		locked   int
+	}
+
+	var (
+		groups []*group_t
+		index  = map[string]*group_t{}
+	)
+
+	for i := range goroutines {
+		g := &goroutines[i]
+
+		key := goroutine_key(g)
+		group, found := index[key]
+		if !found {
+			group = &group_t{g: g, min: g.Wait, max: g.Wait}
+			index[key] = group
+			groups = append(groups, group)
+		}
+
+		group.ids = append(group.ids, strconv.Itoa(g.ID))
+		if g.Wait < group.min {
+			group.min = g.Wait
+		}
+		if g.Wait > group.max {
+			group.max = g.Wait
+		}
+		if g.Locked {
+			group.locked++
+		}
+	}
+
+	for _, group := range groups {
+		var (
+			g     = group.g
+			state = goroutine_state(g.State, group.min, group.max, group.locked, len(group.ids))
+		)
+
+		if len(group.ids) == 1 {
+			fmt.Fprintf(w, "goroutine %s [%s]:\n", group.ids[0], state)
+		} else {
+			ids := group.ids
+			if len(ids) > 8 {
+				ids = append(ids[:8:8], "...")
+			}
+			fmt.Fprintf(w, "%d goroutines (%s) [%s]:\n", len(group.ids), strings.Join(ids, ", "), state)
+		}
+
+		for _, f := range g.Stack {
+			fmt.Fprintf(w, "  %s:%d %s.%s()\n", f.Location(), f.Line, path.Base(f.Package), f.FuncName())
+		}
+
+		if f := g.CreatedBy; f.Function != "" {
+			fmt.Fprintf(w, "  created by %s:%d %s.%s()\n", f.Location(), f.Line, path.Base(f.Package), f.FuncName())
+		}
+	}
+}
+
+// goroutines are grouped by their state (without the wait time and
+// locking), stack and creator.
+func goroutine_key(g *Goroutine) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%s\x00%s:%d\x00", g.State, g.CreatedBy.Filepath, g.CreatedBy.Line)
+	for _, f := range g.Stack {
+		fmt.Fprintf(&buf, "%s\x00%s\x00%d\x00", f.Package, f.FuncName(), f.Line)
+	}
+
+	return buf.String()
+}
+
+// like the runtime: chan receive, 3 minutes, locked to thread. Groups show
+// a range of wait times (3-7 minutes) and how many of their goroutines are
+// locked when only some are.
+func goroutine_state(state string, min, max time.Duration, locked, n int) string {
+	switch {
+	case min != max:
+		state += fmt.Sprintf(", %d-%d minutes", int(min/time.Minute), int(max/time.Minute))
+	case max > 0:
+		state += fmt.Sprintf(", %d minutes", int(max/time.Minute))
+	}
+
+	switch {
+	case locked == n:
+		state += ", locked to thread"
+	case locked > 0:
+		state += fmt.Sprintf(", %d locked to thread", locked)
+	}
+
+	return state
+}
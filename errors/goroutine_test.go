@@ -0,0 +1,88 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const goroutine_dump = `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:12 +0x25
+
+goroutine 18 [chan receive, 3 minutes, locked to thread]:
+example.com/app/worker.(*Pool).run(0xc000010000, {0x5c8e40?, 0x6470b0?})
+	/src/app/worker/pool.go:42 +0x3d
+created by example.com/app/worker.New in goroutine 1
+	/src/app/worker/pool.go:30 +0x55
+
+goroutine 19 [chan receive, 7 minutes, locked to thread]:
+example.com/app/worker.(*Pool).run(0xc000010008, {0x5c8e40?, 0x6470b0?})
+	/src/app/worker/pool.go:42 +0x3d
+created by example.com/app/worker.New in goroutine 1
+	/src/app/worker/pool.go:30 +0x55
+
+goroutine 20 [select]:
+example.com/app/x.Map[...](...)
+	/src/app/x/map.go:7
+...additional frames elided...
+`
+
+func TestParseGoroutines(t *testing.T) {
+	goroutines := parse_goroutines([]byte(goroutine_dump))
+	if len(goroutines) != 4 {
+		t.Fatalf("expected 4 goroutines, got %d", len(goroutines))
+	}
+
+	g := goroutines[1]
+	if g.ID != 18 || g.State != "chan receive" || g.Wait != 3*time.Minute || !g.Locked {
+		t.Errorf("unexpected goroutine: %+v", g)
+	}
+	if f := g.Stack[0]; f.Package != "example.com/app/worker" || f.FuncName() != "(*Pool).run" || f.Filepath != "/src/app/worker/pool.go" || f.Line != 42 {
+		t.Errorf("unexpected frame: %+v", f)
+	}
+	if f := g.CreatedBy; f.Function != "New" || f.Line != 30 {
+		t.Errorf("unexpected creator: %+v", f)
+	}
+	if f := goroutines[3].Stack; len(f) != 1 || f[0].Function != "Map[...]" || f[0].Line != 7 {
+		t.Errorf("unexpected stack: %+v", f)
+	}
+
+	e := New("deadlock")
+	e.goroutines = goroutines
+
+	s := e.Error()
+	if !strings.Contains(s, "    2 goroutines (18, 19) [chan receive, 3-7 minutes, locked to thread]:\n"+
+		"      example.com/app/worker/pool.go:42 worker.(*Pool).run()\n"+
+		"      created by example.com/app/worker/pool.go:30 worker.New()\n") {
+		t.Errorf("expected identical goroutines to be grouped:\n%s", s)
+	}
+}
+
+func TestPanicGoroutines(t *testing.T) {
+	defer func(b bool) { PanicGoroutines = b }(PanicGoroutines)
+	PanicGoroutines = true
+
+	block := make(chan struct{})
+	defer close(block)
+	go func() { <-block }()
+
+	err := Guard(func() error { panic("boom") }).(*Error)
+
+	goroutines := err.Goroutines()
+	if len(goroutines) < 2 || goroutines[0].State != "running" {
+		t.Fatalf("expected a dump starting with the current goroutine, got %+v", goroutines)
+	}
+
+	var found bool
+	for _, g := range goroutines[1:] {
+		for _, f := range g.Stack {
+			if f.Function == "TestPanicGoroutines.func2" && strings.HasSuffix(f.Location(), "errors/goroutine_test.go") {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected the blocked goroutine in the dump:\n%+v", err)
+	}
+}
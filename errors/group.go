@@ -0,0 +1,82 @@
+package errors
+
+import (
+	"context"
+	"sync"
+)
+
+// A Group runs functions in goroutines and collects their errors in a List.
+// Panics are recovered and turned into errors with NewFromPanic. The zero
+// Group runs any number of goroutines and never cancels anything.
+type Group struct {
+	wg     sync.WaitGroup
+	sem    chan struct{}
+	cancel func()
+
+	mtx  sync.Mutex
+	errs List
+}
+
+// Make a new Group and a derived context which is canceled as soon as one
+// of the functions fails (or when Wait returns).
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// Limit the number of goroutines running at once. Go blocks until a
+// goroutine finishes when the limit is reached. SetLimit must be called
+// before Go; n < 1 removes the limit.
+func (g *Group) SetLimit(n int) {
+	if n < 1 {
+		g.sem = nil
+		return
+	}
+
+	g.sem = make(chan struct{}, n)
+}
+
+// Call f in a new goroutine.
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+
+		if err := Guard(f); err != nil {
+			g.mtx.Lock()
+			g.errs.Add(err)
+			g.mtx.Unlock()
+
+			if g.cancel != nil {
+				g.cancel()
+			}
+		}
+	}()
+}
+
+// Wait for all goroutines to finish and return their errors (as a List)
+// or nil.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+
+	if g.cancel != nil {
+		g.cancel()
+	}
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	return g.errs.Normalize()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+
+	g.wg.Done()
+}
@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	var (
+		g       Group
+		running int32
+		peak    int32
+	)
+
+	g.SetLimit(2)
+
+	for i := 0; i < 10; i++ {
+		i := i
+		g.Go(func() error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+
+			switch i {
+			case 3:
+				return io.EOF
+			case 7:
+				panic("boom")
+			}
+			return nil
+		})
+	}
+
+	err := g.Wait()
+
+	l, ok := err.(List)
+	if !ok || len(l) != 2 {
+		t.Fatalf("expected a List of 2 errors, got %#v", err)
+	}
+	if !Is(err, io.EOF) {
+		t.Error("expected io.EOF to be collected")
+	}
+	if peak > 2 {
+		t.Errorf("expected at most 2 goroutines at once, got %d", peak)
+	}
+
+	var panicked bool
+	for _, err := range l {
+		if e, ok := err.(*Error); ok && e.Message() == "panic: boom" {
+			panicked = true
+		}
+	}
+	if !panicked {
+		t.Errorf("expected the panic to be recovered, got %v", l)
+	}
+
+	if err := new(Group).Wait(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestGroupCancel(t *testing.T) {
+	g, ctx := NewGroup(context.Background())
+
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	g.Go(func() error {
+		return Conflict("failed")
+	})
+
+	err := g.Wait()
+	if KindOf(err) != KindConflict || !Is(err, context.Canceled) {
+		t.Errorf("expected the first error to cancel the others, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+// Package httperr turns errors into application/problem+json (RFC 7807)
+// responses.
+package httperr
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/fd/go-util/errors"
+	"github.com/fd/go-util/log"
+)
+
+// The body of an error response. Detail is the public message of the error
+// (see errors.WithPublic) or, for client errors (4xx), the generic message
+// for its kind (see errors.SafeMessage). Everything else stays internal and
+// can be found by its Incident id.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Incident string `json:"incident"`
+}
+
+// Report is called for each error written by WriteError. err is annotated
+// with the request and carries the incident and status as fields. The
+// default logs server errors (5xx).
+var Report = func(r *http.Request, status int, err *errors.Error) {
+	if status >= 500 {
+		log.WithError(err).Error(err)
+	}
+}
+
+// The HTTP status code for err. Errors are mapped by their kind; errors
+// without a kind are 503 when they are not fatal and 500 otherwise.
+func StatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	switch errors.KindOf(err) {
+	case errors.KindNotFound:
+		return http.StatusNotFound
+	case errors.KindConflict:
+		return http.StatusConflict
+	case errors.KindInvalidArgument:
+		return http.StatusBadRequest
+	case errors.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case errors.KindTimeout:
+		return http.StatusGatewayTimeout
+	case errors.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case errors.KindInternal:
+		return http.StatusInternalServerError
+	}
+
+	if !errors.IsFatal(err) {
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// Make the Problem for err (with a new incident id).
+func NewProblem(err error) *Problem {
+	status := StatusCode(err)
+
+	p := &Problem{
+		Title:    http.StatusText(status),
+		Status:   status,
+		Incident: new_incident(),
+	}
+
+	if kind := errors.KindOf(err); kind != errors.KindUnknown {
+		p.Kind = kind.String()
+	}
+
+	if status >= 400 && status < 500 {
+		p.Detail = errors.SafeMessage(err)
+	} else {
+		p.Detail = errors.PublicMessage(err)
+	}
+
+	return p
+}
+
+// Write err to w as a problem+json response (or as an HTML error page
+// behind DevMode) and report it. Returns the incident id. A nil err writes
+// nothing and returns "".
+func WriteError(w http.ResponseWriter, r *http.Request, err error) string {
+	if err == nil {
+		return ""
+	}
+
+	p := NewProblem(err)
+
+	e := errors.Annotate(err, "%s %s", r.Method, r.URL.Path)
+	e = e.With("incident", p.Incident).With("status", p.Status)
+
+	if Report != nil {
+		Report(r, p.Status, e)
+	}
+
+	if dev_mode(r) {
+		write_page(w, p.Status, e)
+	} else {
+		write_problem(w, p)
+	}
+
+	return p.Incident
+}
+
+// Adapt a handler which returns an error. The error (or a panic) is written
+// with WriteError.
+func Handler(f func(w http.ResponseWriter, r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, func(w http.ResponseWriter) error {
+			return f(w, r)
+		})
+	})
+}
+
+// Render errors (and panics) in h as HTML pages with the full report
+// instead of problem+json responses. Only for development: the pages show
+// source code and internal messages.
+func DevMode(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), dev_mode_key{}, true))
+
+		serve(w, r, func(w http.ResponseWriter) error {
+			h.ServeHTTP(w, r)
+			return nil
+		})
+	})
+}
+
+type dev_mode_key struct{}
+
+func dev_mode(r *http.Request) bool {
+	on, _ := r.Context().Value(dev_mode_key{}).(bool)
+	return on
+}
+
+// Recover panics in h and write them with WriteError. Panics with
+// http.ErrAbortHandler are passed on.
+func Recover(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, func(w http.ResponseWriter) error {
+			h.ServeHTTP(w, r)
+			return nil
+		})
+	})
+}
+
+func serve(w http.ResponseWriter, r *http.Request, f func(w http.ResponseWriter) error) {
+	rw := &response_writer_t{ResponseWriter: w}
+
+	err := errors.Guard(func() error { return f(rw) })
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, http.ErrAbortHandler) {
+		panic(http.ErrAbortHandler)
+	}
+
+	if rw.wrote_header {
+		// too late for an error response; only report it
+		if Report != nil {
+			Report(r, StatusCode(err), errors.Annotate(err, "%s %s", r.Method, r.URL.Path))
+		}
+		return
+	}
+
+	WriteError(w, r, err)
+}
+
+func write_problem(w http.ResponseWriter, p *Problem) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		panic(err)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	w.WriteHeader(p.Status)
+	w.Write(data)
+}
+
+func write_page(w http.ResponseWriter, status int, err error) {
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Del("Content-Length")
+	w.WriteHeader(status)
+	errors.HTML.Render(w, err)
+}
+
+func new_incident() string {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b[:])
+}
+
+type response_writer_t struct {
+	http.ResponseWriter
+	wrote_header bool
+}
+
+func (w *response_writer_t) WriteHeader(code int) {
+	w.wrote_header = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *response_writer_t) Write(data []byte) (int, error) {
+	w.wrote_header = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *response_writer_t) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wrote_header = true
+		f.Flush()
+	}
+}
+
+func (w *response_writer_t) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("httperr: the ResponseWriter does not implement http.Hijacker")
+	}
+
+	// errors can no longer be written once the connection is taken over
+	w.wrote_header = true
+	return h.Hijack()
+}
+
+// The wrapped ResponseWriter (for http.ResponseController)
+func (w *response_writer_t) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,196 @@
+package httperr
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fd/go-util/errors"
+)
+
+func TestStatusCode(t *testing.T) {
+	unavailable := errors.New("down")
+	unavailable.SetFatal(false)
+
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{nil, 200},
+		{errors.NotFound("no user"), 404},
+		{errors.Annotate(errors.Conflict("taken"), "signup"), 409},
+		{errors.InvalidArgument("bad"), 400},
+		{errors.Unauthenticated("who?"), 401},
+		{errors.Timeout("slow"), 504},
+		{errors.Unavailable("down"), 503},
+		{errors.Internal("bug"), 500},
+		{unavailable, 503},
+		{io.EOF, 500},
+	}
+
+	for _, test := range tests {
+		if status := StatusCode(test.err); status != test.status {
+			t.Errorf("%v: expected %d, got %d", test.err, test.status, status)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var reported []*errors.Error
+
+	defer func(f func(*http.Request, int, *errors.Error)) { Report = f }(Report)
+	Report = func(r *http.Request, status int, err *errors.Error) {
+		reported = append(reported, err)
+	}
+
+	tests := []struct {
+		handler func(w http.ResponseWriter, r *http.Request) error
+		status  int
+		detail  string
+	}{
+		{
+			func(w http.ResponseWriter, r *http.Request) error {
+				return errors.Annotate(errors.NotFound("no such user"), "lookup id=%d", 42)
+			},
+			404, "not found",
+		},
+		{
+			func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("db password=hunter2 rejected")
+			},
+			500, "",
+		},
+		{
+			func(w http.ResponseWriter, r *http.Request) error {
+				panic("boom")
+			},
+			500, "",
+		},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		Handler(test.handler).ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+		var p Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != test.status || p.Status != test.status || p.Detail != test.detail {
+			t.Errorf("%d: unexpected response %d %+v", i, w.Code, p)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%d: unexpected content type %q", i, ct)
+		}
+		if len(p.Incident) != 32 || reported[i].Fields()["incident"] != p.Incident {
+			t.Errorf("%d: expected the incident to be reported, got %q", i, p.Incident)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	defer func(f func(*http.Request, int, *errors.Error)) { Report = f }(Report)
+	Report = nil
+
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 500 {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+
+	h = Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		panic("boom")
+	}))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 || w.Body.String() != "partial" {
+		t.Errorf("expected the response to be left alone, got %d %q", w.Code, w.Body)
+	}
+
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be passed on, got %v", r)
+		}
+	}()
+
+	h = Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestDevMode(t *testing.T) {
+	defer func(f func(*http.Request, int, *errors.Error)) { Report = f }(Report)
+	Report = nil
+
+	h := DevMode(Handler(func(w http.ResponseWriter, r *http.Request) error {
+		return errors.NotFound("no <user>")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+	body := w.Body.String()
+	if w.Code != 404 || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, "<h1><span class=\"label\">error:</span> GET /users/42</h1>") ||
+		!strings.Contains(body, "no &lt;user&gt;") || !strings.Contains(body, "<th>incident</th>") {
+		t.Errorf("expected the full report:\n%s", body)
+	}
+
+	h = DevMode(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "panic: boom") {
+		t.Errorf("expected the panic to be rendered, got %d:\n%s", w.Code, w.Body)
+	}
+}
+
+func TestPublicDetail(t *testing.T) {
+	err := errors.Annotate(errors.New("pq: deadlock"), "save order").WithPublic("Your order could not be saved")
+
+	p := NewProblem(err)
+	if p.Status != 500 || p.Detail != "Your order could not be saved" {
+		t.Errorf("expected the public message, got %+v", p)
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	if id := WriteError(w, httptest.NewRequest("GET", "/", nil), nil); id != "" || w.Body.Len() != 0 {
+		t.Errorf("expected a nil error to write nothing, got %q %q", id, w.Body)
+	}
+
+	var hijack_err error
+	h := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if h, ok := w.(http.Hijacker); ok {
+			_, _, hijack_err = h.Hijack()
+		}
+	}))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.Flushed {
+		t.Error("expected Flush to be forwarded")
+	}
+	if hijack_err == nil {
+		t.Error("expected Hijack to fail for a ResponseWriter which can't be hijacked")
+	}
+}
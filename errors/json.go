@@ -0,0 +1,256 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Include the source context of the stack frames in the JSON encoding
+var JSONSourceContext = false
+
+// An error from another package which was decoded from JSON
+type RemoteError struct {
+	Type    string
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+type json_error_t struct {
+	Type    string                 `json:"type"`
+	Message string                 `json:"message,omitempty"`
+	Format  string                 `json:"format,omitempty"`
+	Public  string                 `json:"public,omitempty"`
+	Context map[string]interface{} `json:"context,omitempty"`
+	Fatal   bool                   `json:"fatal,omitempty"`
+	Kind    string                 `json:"kind,omitempty"`
+	Stack   []*json_frame_t        `json:"stack,omitempty"`
+	Cause   *json_error_t          `json:"cause,omitempty"`
+	Errors  []*json_error_t        `json:"errors,omitempty"`
+}
+
+type json_frame_t struct {
+	PC       uintptr `json:"pc"`
+	Module   string  `json:"module,omitempty"`
+	Package  string  `json:"package"`
+	Receiver string  `json:"receiver,omitempty"`
+	Function string  `json:"function"`
+	Filename string  `json:"filename"`
+	Filepath string  `json:"abs_path"`
+	Line     int     `json:"line"`
+	Inlined  bool    `json:"inlined,omitempty"`
+	InApp    bool    `json:"in_app"`
+
+	PreContext  []string `json:"pre_context,omitempty"`
+	ContextLine string   `json:"context_line,omitempty"`
+	PostContext []string `json:"post_context,omitempty"`
+}
+
+const (
+	c_JSON_TYPE_ERROR = "error"
+	c_JSON_TYPE_LIST  = "list"
+)
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	if e == nil {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(encode_json(e))
+}
+
+func (l List) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encode_json(l))
+}
+
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var j json_error_t
+
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	if j.Type != c_JSON_TYPE_ERROR {
+		return fmt.Errorf("errors: expected a JSON encoded %q but got %q", c_JSON_TYPE_ERROR, j.Type)
+	}
+
+	*e = *decode_json(&j).(*Error)
+	return nil
+}
+
+func (l *List) UnmarshalJSON(data []byte) error {
+	var j json_error_t
+
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	if j.Type != c_JSON_TYPE_LIST {
+		return fmt.Errorf("errors: expected a JSON encoded %q but got %q", c_JSON_TYPE_LIST, j.Type)
+	}
+
+	*l = decode_json(&j).(List)
+	return nil
+}
+
+// Decode an error encoded with MarshalJSON. The result is either an *Error,
+// a List or a *RemoteError (for errors from other packages).
+func DecodeJSON(data []byte) (error, error) {
+	var j *json_error_t
+
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+
+	if j == nil {
+		return nil, nil
+	}
+
+	return decode_json(j), nil
+}
+
+// nil errors (also a nil *Error in a List) are encoded as null
+func encode_json(err error) *json_error_t {
+	switch e := err.(type) {
+
+	case nil:
+		return nil
+
+	case *Error:
+		if e == nil {
+			return nil
+		}
+
+		j := &json_error_t{
+			Type:    c_JSON_TYPE_ERROR,
+			Message: e.message,
+			Format:  e.format,
+			Public:  e.public,
+			Fatal:   e.fatal,
+		}
+
+		if e.kind != KindUnknown {
+			j.Kind = e.kind.String()
+		}
+
+		if len(e.context) > 0 {
+			j.Context = make(map[string]interface{}, len(e.context))
+			for _, f := range e.context {
+				j.Context[f.key] = redact(f.key, f.value)
+			}
+		}
+
+		stack := e.Stack()
+		if JSONSourceContext {
+			stack = stack.WithContext()
+		}
+
+		for _, f := range stack {
+			j.Stack = append(j.Stack, encode_json_frame(f))
+		}
+
+		if e.err != nil {
+			j.Cause = encode_json(e.err)
+		}
+
+		return j
+
+	case List:
+		j := &json_error_t{Type: c_JSON_TYPE_LIST}
+		for _, err := range e {
+			j.Errors = append(j.Errors, encode_json(err))
+		}
+		return j
+
+	default:
+		return &json_error_t{
+			Type:    fmt.Sprintf("%T", err),
+			Message: err.Error(),
+		}
+
+	}
+}
+
+func encode_json_frame(f StackFrame) *json_frame_t {
+	j := &json_frame_t{
+		PC:       f.PC,
+		Module:   f.Module,
+		Package:  f.Package,
+		Receiver: f.Receiver,
+		Function: f.Function,
+		Filename: f.Filename,
+		Filepath: f.Filepath,
+		Line:     f.Line,
+		Inlined:  f.Inlined,
+		InApp:    f.InApp,
+	}
+
+	if f.HasContext {
+		j.PreContext = f.PreContext
+		j.ContextLine = f.ContextLine
+		j.PostContext = f.PostContext
+	}
+
+	return j
+}
+
+func decode_json(j *json_error_t) error {
+	switch j.Type {
+
+	case c_JSON_TYPE_ERROR:
+		e := &Error{
+			message: j.Message,
+			format:  j.Format,
+			public:  j.Public,
+			fatal:   j.Fatal,
+			kind:    parse_kind(j.Kind),
+		}
+
+		for key, value := range j.Context {
+			e.context = append(e.context, field_t{key: key, value: value, has_value: value != nil})
+		}
+		e.context = sorted_fields(e.context)
+
+		for _, f := range j.Stack {
+			e.stack = append(e.stack, StackFrame{
+				PC:          f.PC,
+				Module:      f.Module,
+				Package:     f.Package,
+				Receiver:    f.Receiver,
+				Function:    f.Function,
+				Filename:    f.Filename,
+				Filepath:    f.Filepath,
+				Line:        f.Line,
+				Inlined:     f.Inlined,
+				InApp:       f.InApp,
+				HasContext:  f.ContextLine != "" || len(f.PreContext) > 0 || len(f.PostContext) > 0,
+				PreContext:  f.PreContext,
+				ContextLine: f.ContextLine,
+				PostContext: f.PostContext,
+			})
+		}
+
+		if j.Cause != nil {
+			e.err = decode_json(j.Cause)
+		}
+
+		return e
+
+	case c_JSON_TYPE_LIST:
+		l := make(List, 0, len(j.Errors))
+		for _, err := range j.Errors {
+			if err == nil {
+				l = append(l, (*Error)(nil))
+				continue
+			}
+			l = append(l, decode_json(err))
+		}
+		return l
+
+	default:
+		return &RemoteError{Type: j.Type, Message: j.Message}
+
+	}
+}
@@ -0,0 +1,69 @@
+package errors
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	err1 := New("%s err", "foo").With("user", 7)
+	err1.AddContext("flag")
+	err1.SetFatal(false)
+
+	var list List
+	list.Add(err1)
+	list.Add(io.EOF)
+
+	err2 := Annotate(list, "%s err", "bar")
+
+	data, err := json.Marshal(err2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := decoded.(*Error)
+	if !ok || e.Message() != "bar err" || !IsFatal(e) {
+		t.Fatalf("unexpected error: %#v", decoded)
+	}
+	if len(e.Stack()) != len(err2.Stack()) || e.Stack()[0].Line != err2.Stack()[0].Line {
+		t.Errorf("expected the stack to be decoded")
+	}
+
+	l, ok := e.Unwrap().(List)
+	if !ok || len(l) != 2 {
+		t.Fatalf("expected the cause to be a List, got %#v", e.Unwrap())
+	}
+
+	inner, ok := l[0].(*Error)
+	if !ok || inner.Message() != "foo err" || IsFatal(inner) {
+		t.Fatalf("unexpected inner error: %#v", l[0])
+	}
+	if f := inner.Fields(); f["user"] != float64(7) || f["flag"] != nil {
+		t.Errorf("unexpected fields: %v", f)
+	}
+
+	remote, ok := l[1].(*RemoteError)
+	if !ok || remote.Message != "EOF" || remote.Type != "*errors.errorString" {
+		t.Errorf("unexpected remote error: %#v", l[1])
+	}
+
+	var e2 Error
+	if err := json.Unmarshal(data, &e2); err != nil || e2.Message() != "bar err" {
+		t.Errorf("failed to unmarshal into an Error: %v", err)
+	}
+
+	data, err = json.Marshal(List{New("a"), (*Error)(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = DecodeJSON(data)
+	if l, ok := decoded.(List); err != nil || !ok || len(l) != 2 || l[1] != (*Error)(nil) {
+		t.Errorf("expected a nil *Error to round-trip, got %#v (%v)", decoded, err)
+	}
+}
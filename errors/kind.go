@@ -0,0 +1,206 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// The category of an error
+type Kind uint8
+
+const (
+	KindUnknown Kind = iota
+	KindNotFound
+	KindConflict
+	KindInvalidArgument
+	KindUnauthenticated
+	KindTimeout
+	KindUnavailable
+	KindInternal
+)
+
+var kind_names = map[Kind]string{
+	KindUnknown:         "unknown",
+	KindNotFound:        "not_found",
+	KindConflict:        "conflict",
+	KindInvalidArgument: "invalid_argument",
+	KindUnauthenticated: "unauthenticated",
+	KindTimeout:         "timeout",
+	KindUnavailable:     "unavailable",
+	KindInternal:        "internal",
+}
+
+func (k Kind) String() string {
+	if name, found := kind_names[k]; found {
+		return name
+	}
+
+	return fmt.Sprintf("kind(%d)", uint8(k))
+}
+
+// Errors of temporary kinds (Timeout and Unavailable) may succeed when
+// retried.
+func (k Kind) Temporary() bool {
+	return k == KindTimeout || k == KindUnavailable
+}
+
+func parse_kind(s string) Kind {
+	for k, name := range kind_names {
+		if name == s {
+			return k
+		}
+	}
+
+	return KindUnknown
+}
+
+// Make a new Error of the NotFound kind
+func NotFound(message string, args ...interface{}) *Error {
+	return new_kind(KindNotFound, message, args)
+}
+
+// Make a new Error of the Conflict kind
+func Conflict(message string, args ...interface{}) *Error {
+	return new_kind(KindConflict, message, args)
+}
+
+// Make a new Error of the InvalidArgument kind
+func InvalidArgument(message string, args ...interface{}) *Error {
+	return new_kind(KindInvalidArgument, message, args)
+}
+
+// Make a new Error of the Unauthenticated kind
+func Unauthenticated(message string, args ...interface{}) *Error {
+	return new_kind(KindUnauthenticated, message, args)
+}
+
+// Make a new Error of the Timeout kind (the error is not fatal)
+func Timeout(message string, args ...interface{}) *Error {
+	return new_kind(KindTimeout, message, args)
+}
+
+// Make a new Error of the Unavailable kind (the error is not fatal)
+func Unavailable(message string, args ...interface{}) *Error {
+	return new_kind(KindUnavailable, message, args)
+}
+
+// Make a new Error of the Internal kind
+func Internal(message string, args ...interface{}) *Error {
+	return new_kind(KindInternal, message, args)
+}
+
+func new_kind(kind Kind, message string, args []interface{}) *Error {
+	e := new_error(2, nil, message, args)
+	e.fatal = !kind.Temporary()
+	e.kind = kind
+	return e
+}
+
+func (e *Error) SetKind(kind Kind) {
+	if e == nil {
+		return
+	}
+
+	e.kind = kind
+}
+
+// The kind set on this error (without looking at its causes; see KindOf)
+func (e *Error) Kind() Kind {
+	if e == nil {
+		return KindUnknown
+	}
+
+	return e.kind
+}
+
+// The kind of err. The outermost kind in the annotation chain wins; Lists
+// report the kind of their first classified error. Other errors are
+// classified by the registered extractors.
+func KindOf(err error) Kind {
+	switch e := err.(type) {
+
+	case nil:
+		return KindUnknown
+
+	case *Error:
+		if e == nil {
+			return KindUnknown
+		}
+		if e.kind != KindUnknown {
+			return e.kind
+		}
+		return KindOf(e.err)
+
+	case List:
+		for _, err := range e {
+			if kind := KindOf(err); kind != KindUnknown {
+				return kind
+			}
+		}
+		return KindUnknown
+
+	}
+
+	if kind := extract_kind(err); kind != KindUnknown {
+		return kind
+	}
+
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return KindOf(e.Unwrap())
+	case interface{ Unwrap() []error }:
+		return KindOf(List(e.Unwrap()))
+	}
+
+	return KindUnknown
+}
+
+var (
+	kind_extractors_mtx sync.RWMutex
+	kind_extractors     []func(error) Kind
+)
+
+func init() {
+	RegisterKindExtractor(func(err error) Kind {
+		switch err {
+		case os.ErrNotExist:
+			return KindNotFound
+		case os.ErrExist:
+			return KindConflict
+		case context.DeadlineExceeded, os.ErrDeadlineExceeded:
+			return KindTimeout
+		}
+
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			return KindTimeout
+		}
+
+		return KindUnknown
+	})
+}
+
+// Register a function which classifies errors from other packages. f must
+// return KindUnknown for errors it doesn't know. Extractors are tried in
+// order of registration for each error in the chain.
+func RegisterKindExtractor(f func(err error) Kind) {
+	kind_extractors_mtx.Lock()
+	defer kind_extractors_mtx.Unlock()
+
+	kind_extractors = append(kind_extractors, f)
+}
+
+func extract_kind(err error) Kind {
+	kind_extractors_mtx.RLock()
+	defer kind_extractors_mtx.RUnlock()
+
+	for _, f := range kind_extractors {
+		if kind := f(err); kind != KindUnknown {
+			return kind
+		}
+	}
+
+	return KindUnknown
+}
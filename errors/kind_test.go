@@ -0,0 +1,78 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	err := NotFound("user %d", 7)
+	if err.Kind() != KindNotFound || !IsFatal(err) || err.Message() != "user 7" {
+		t.Errorf("unexpected error: %#v", err)
+	}
+	if f := err.Stack()[0]; f.Function != "TestKind" {
+		t.Errorf("expected the stack to start at the caller, got %s", f.FuncName())
+	}
+	if IsFatal(Timeout("slow")) || IsFatal(Unavailable("down")) {
+		t.Error("expected temporary kinds not to be fatal")
+	}
+
+	tests := []struct {
+		err  error
+		kind Kind
+	}{
+		{nil, KindUnknown},
+		{New("plain"), KindUnknown},
+		{Annotate(err, "outer"), KindNotFound},
+		{Annotate(Annotate(err, "inner"), "outer"), KindNotFound},
+		{Annotate(List{New("a"), Conflict("b")}, "outer"), KindConflict},
+		{Annotate(&os.PathError{Op: "open", Path: "/x", Err: os.ErrNotExist}, "outer"), KindNotFound},
+		{Annotate(context.DeadlineExceeded, "outer"), KindTimeout},
+		{fmt.Errorf("wrapped: %w", Unavailable("down")), KindUnavailable},
+	}
+
+	for _, test := range tests {
+		if kind := KindOf(test.err); kind != test.kind {
+			t.Errorf("%v: expected %s, got %s", test.err, test.kind, kind)
+		}
+	}
+
+	outer := Annotate(err, "outer")
+	outer.SetKind(KindInternal)
+	if KindOf(outer) != KindInternal {
+		t.Error("expected the outermost kind to win")
+	}
+
+	custom := &os.SyscallError{Syscall: "custom", Err: io.ErrClosedPipe}
+	restore_kind_extractors(t)
+	RegisterKindExtractor(func(err error) Kind {
+		if err == io.ErrClosedPipe {
+			return KindUnavailable
+		}
+		return KindUnknown
+	})
+	if KindOf(custom) != KindUnavailable {
+		t.Error("expected the registered extractor to be used")
+	}
+
+	if !strings.Contains(err.Error(), "  kind: not_found\n") {
+		t.Errorf("expected the kind to be rendered:\n%s", err)
+	}
+}
+
+// restore the registered kind extractors when t is done
+func restore_kind_extractors(t *testing.T) {
+	kind_extractors_mtx.RLock()
+	saved := kind_extractors
+	kind_extractors_mtx.RUnlock()
+
+	t.Cleanup(func() {
+		kind_extractors_mtx.Lock()
+		kind_extractors = saved
+		kind_extractors_mtx.Unlock()
+	})
+}
@@ -35,12 +35,34 @@ func (l *List) Add(err error) {
 	*l = append(*l, err)
 }
 
+// The errors in the list
+func (l List) Unwrap() []error {
+	return []error(l)
+}
+
+// The errors formatted according to DefaultMode
 func (l List) Error() string {
+	if DefaultMode == ShortMode {
+		return l.short()
+	}
+
+	return l.full()
+}
+
+func (l List) full() string {
+	return RenderString(DefaultRenderer, l)
+}
+
+func (l List) short() string {
+	if len(l) == 1 {
+		return short_string(l[0])
+	}
+
 	s := make([]string, len(l))
 
 	for i, err := range l {
-		s[i] = err.Error()
+		s[i] = short_string(err)
 	}
 
-	return strings.Join(s, "\n")
+	return "[" + strings.Join(s, "; ") + "]"
 }
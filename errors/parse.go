@@ -0,0 +1,370 @@
+package errors
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An error report (in the format of the Plain renderer) parsed by
+// ParseReports.
+type Report struct {
+	Message    string
+	Detail     string                 // the message of a cause which is not an Error or List
+	Public     string                 //
+	Kind       Kind                   //
+	Fields     map[string]interface{} // the context (keys without a value map to nil)
+	Stack      Stack                  // Package is only the last element of the import path
+	Shared     int                    // the number of frames left out because the cause shows them
+	Goroutines []Goroutine            //
+	Causes     []*Report              // the cause (or the errors of a List cause)
+	Foreign    bool                   // not an Error: only Message is set
+}
+
+// Parse the reports written by the Plain renderer (like Error() in
+// FullMode). Collapsed library frames are skipped.
+func ParseReports(r io.Reader) ([]*Report, error) {
+	var (
+		p = report_parser_t{}
+		s = bufio.NewScanner(r)
+	)
+
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		p.lines = append(p.lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	reports := p.reports(0)
+
+	p.skip_blank()
+	if p.pos < len(p.lines) {
+		return reports, fmt.Errorf("errors: unexpected line %d: %q", p.pos+1, p.lines[p.pos])
+	}
+
+	return reports, nil
+}
+
+// The report in the format of the Plain renderer
+func (r *Report) String() string {
+	return text_renderer_t{}.text(r.report())
+}
+
+func (r *Report) report() *report_t {
+	if r.Foreign {
+		return &report_t{foreign: true, message: r.Message}
+	}
+
+	o := &report_t{
+		message:    r.Message,
+		detail:     r.Detail,
+		public:     r.Public,
+		kind:       r.Kind,
+		stack:      r.Stack,
+		shared:     r.Shared,
+		goroutines: r.Goroutines,
+	}
+
+	for key, value := range r.Fields {
+		o.context = append(o.context, field_t{key: key, value: value, has_value: value != nil})
+	}
+	o.context = sorted_fields(o.context)
+
+	switch len(r.Causes) {
+	case 0:
+	case 1:
+		o.cause = r.Causes[0].report()
+	default:
+		o.cause = &report_t{list: make([]*report_t, len(r.Causes))}
+		for i, c := range r.Causes {
+			o.cause.list[i] = c.report()
+		}
+	}
+
+	return o
+}
+
+type report_parser_t struct {
+	lines []string
+	pos   int
+}
+
+var (
+	report_frame_re     = regexp.MustCompile(`^(.+):(\d+) ([^ ]+)\(\) \(0x([0-9a-f]+)\)$`)
+	report_shared_re    = regexp.MustCompile(`^\.\.\. (\d+) frames? shared with cause$`)
+	report_goroutine_re = regexp.MustCompile(`^(?:goroutine (\d+)|\d+ goroutines \(([0-9, .]+)\)) \[(.*)\]:$`)
+	report_g_frame_re   = regexp.MustCompile(`^(created by )?(.+):(\d+) ([^ ]+)\(\)$`)
+)
+
+// the errors at indent (separated by blank lines)
+func (p *report_parser_t) reports(indent int) []*Report {
+	var reports []*Report
+
+	for {
+		p.skip_blank()
+
+		line, ok := p.line(indent)
+		if !ok {
+			return reports
+		}
+
+		if strings.HasPrefix(line, "error: ") {
+			reports = append(reports, p.report(indent))
+			continue
+		}
+
+		p.pos++
+		reports = append(reports, &Report{Message: line, Foreign: true})
+	}
+}
+
+func (p *report_parser_t) report(indent int) *Report {
+	line, _ := p.line(indent)
+	p.pos++
+
+	r := &Report{Message: strings.TrimPrefix(line, "error: ")}
+
+	for {
+		line, ok := p.line(indent + 2)
+		if !ok {
+			return r
+		}
+
+		switch {
+		case strings.HasPrefix(line, "message: "):
+			p.pos++
+			r.Detail = strings.TrimPrefix(line, "message: ")
+
+		case strings.HasPrefix(line, "public: "):
+			p.pos++
+			r.Public = strings.TrimPrefix(line, "public: ")
+
+		case strings.HasPrefix(line, "kind: "):
+			p.pos++
+			r.Kind = parse_kind(strings.TrimPrefix(line, "kind: "))
+
+		case line == "context:":
+			p.pos++
+			r.Fields = p.fields(indent + 4)
+
+		case line == "location:":
+			p.pos++
+			r.Stack, r.Shared = p.stack(indent + 4)
+
+		case line == "goroutines:":
+			p.pos++
+			r.Goroutines = p.goroutines(indent + 4)
+
+		default:
+			r.Causes = p.reports(indent + 2)
+			return r
+		}
+	}
+}
+
+func (p *report_parser_t) fields(indent int) map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	for {
+		line, ok := p.line(indent)
+		if !ok {
+			return fields
+		}
+		p.pos++
+
+		if i := strings.Index(line, "= "); i >= 0 {
+			fields[strings.TrimRight(line[:i], " ")] = line[i+2:]
+		} else {
+			fields[strings.TrimRight(line, " ")] = nil
+		}
+	}
+}
+
+func (p *report_parser_t) stack(indent int) (stack Stack, shared int) {
+	for {
+		line, ok := p.line(indent)
+		if !ok {
+			return stack, shared
+		}
+		p.pos++
+
+		if m := report_shared_re.FindStringSubmatch(line); m != nil {
+			shared, _ = strconv.Atoi(m[1])
+			continue
+		}
+
+		m := report_frame_re.FindStringSubmatch(line)
+		if m == nil {
+			// like ... 3 library frames (net/http)
+			continue
+		}
+
+		frame := StackFrame{Filename: m[1]}
+		frame.Line, _ = strconv.Atoi(m[2])
+		frame.Package, frame.Receiver, frame.Function = parse_function(m[3])
+		pc, _ := strconv.ParseUint(m[4], 16, 64)
+		frame.PC = uintptr(pc)
+
+		p.source_context(indent, &frame)
+
+		stack = append(stack, frame)
+	}
+}
+
+// the lines of source context like "> 21     err := f()" (the line numbers
+// are padded to the width of the largest one)
+func (p *report_parser_t) source_context(indent int, frame *StackFrame) {
+	var (
+		lines []string
+		width int
+	)
+
+	for ; p.pos < len(p.lines); p.pos++ {
+		line, ok := p.line(indent)
+		if !ok || len(line) < 3 || (line[0] != ' ' && line[0] != '>') || line[1] != ' ' {
+			break
+		}
+
+		n := 0
+		for n < len(line)-2 && line[2+n] >= '0' && line[2+n] <= '9' {
+			n++
+		}
+		if n == 0 {
+			break
+		}
+		if n > width {
+			width = n
+		}
+
+		lines = append(lines, line)
+	}
+
+	for _, line := range lines {
+		text := ""
+		if len(line) > 3+width {
+			text = line[3+width:]
+		}
+
+		switch {
+		case line[0] == '>':
+			frame.HasContext = true
+			frame.ContextLine = text
+		case frame.HasContext:
+			frame.PostContext = append(frame.PostContext, text)
+		default:
+			frame.PreContext = append(frame.PreContext, text)
+		}
+	}
+}
+
+// the wait range (3-7 minutes) and the number of locked goroutines (all
+// for locked to thread) in the state of a group of n goroutines.
+func parse_group_attrs(state string, n int) (min, max time.Duration, locked int) {
+	for _, attr := range strings.Split(state, ", ") {
+		switch {
+		case strings.HasSuffix(attr, " minutes"):
+			bounds := strings.SplitN(strings.TrimSuffix(attr, " minutes"), "-", 2)
+			a, _ := strconv.Atoi(bounds[0])
+			b := a
+			if len(bounds) == 2 {
+				b, _ = strconv.Atoi(bounds[1])
+			}
+			min, max = time.Duration(a)*time.Minute, time.Duration(b)*time.Minute
+		case attr == "locked to thread":
+			locked = n
+		case strings.HasSuffix(attr, " locked to thread"):
+			locked, _ = strconv.Atoi(strings.TrimSuffix(attr, " locked to thread"))
+		}
+	}
+
+	return min, max, locked
+}
+
+func (p *report_parser_t) goroutines(indent int) []Goroutine {
+	var (
+		goroutines []Goroutine
+		group      []int
+	)
+
+	for {
+		line, ok := p.line(indent)
+		if !ok {
+			break
+		}
+		p.pos++
+
+		if m := report_goroutine_re.FindStringSubmatch(line); m != nil {
+			g := parse_goroutine_header("goroutine 0 [" + m[3] + "]:")
+
+			group = group[:0]
+			if m[1] != "" {
+				id, _ := strconv.Atoi(m[1])
+				group = append(group, id)
+			}
+			for _, id := range strings.Split(m[2], ", ") {
+				if id, err := strconv.Atoi(id); err == nil {
+					group = append(group, id)
+				}
+			}
+
+			min, max, locked := parse_group_attrs(m[3], len(group))
+			for i, id := range group {
+				// which goroutine of a group waited how long (or is locked)
+				// is lost; the first gets the lower bound of the range
+				g.ID, g.Wait, g.Locked = id, max, i < locked
+				if i == 0 {
+					g.Wait = min
+				}
+				goroutines = append(goroutines, g)
+			}
+			continue
+		}
+
+		m := report_g_frame_re.FindStringSubmatch(strings.TrimPrefix(line, "  "))
+		if m == nil || len(group) == 0 {
+			continue
+		}
+
+		frame := StackFrame{Filename: m[2]}
+		frame.Line, _ = strconv.Atoi(m[3])
+		frame.Package, frame.Receiver, frame.Function = parse_function(m[4])
+
+		// the goroutines of a group share their stack
+		for i := len(goroutines) - len(group); i < len(goroutines); i++ {
+			g := &goroutines[i]
+			if m[1] != "" {
+				g.CreatedBy = frame
+			} else {
+				g.Stack = append(g.Stack, frame)
+			}
+		}
+	}
+
+	return goroutines
+}
+
+// the current line without indent (if it is indented at least that much)
+func (p *report_parser_t) line(indent int) (string, bool) {
+	if p.pos >= len(p.lines) {
+		return "", false
+	}
+
+	line := p.lines[p.pos]
+	if len(line) <= indent || strings.TrimLeft(line[:indent], " ") != "" || strings.TrimSpace(line) == "" {
+		return "", false
+	}
+
+	return line[indent:], true
+}
+
+func (p *report_parser_t) skip_blank() {
+	for p.pos < len(p.lines) && strings.TrimSpace(p.lines[p.pos]) == "" {
+		p.pos++
+	}
+}
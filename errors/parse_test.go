@@ -0,0 +1,81 @@
+package errors
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReports(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.txt")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no golden files: %v", err)
+	}
+
+	for _, name := range files {
+		check_round_trip(t, name, read_file(t, name))
+	}
+
+	reports, _ := ParseReports(strings.NewReader(read_file(t, "testdata/annotate.txt")))
+	r := reports[0]
+	if r.Message != "bar err" || r.Fields["a"] != "42" || len(r.Causes) != 1 || r.Causes[0].Fields["hello"] != "world" {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if f := r.Stack[0]; f.Location() != "github.com/fd/go-util/errors/golden_test.go" || f.FuncName() != "TestAnnotateNested" ||
+		f.ContextLine != `    err2 := errors.Annotate(err1, "%s err", "bar")` || len(f.PreContext) != 3 || len(f.PostContext) != 3 {
+		t.Errorf("unexpected frame: %+v", f)
+	}
+}
+
+func TestParseReportsRoundTrip(t *testing.T) {
+	inner := Timeout("slow")
+	inner.AddContext("flag")
+	inner.With("empty", "")
+	inner.goroutines = parse_goroutines([]byte(goroutine_dump))
+
+	err := List{
+		Annotate(List{Annotate(inner, "first"), io.EOF, New("second")}, "outer"),
+		Annotate(io.ErrUnexpectedEOF, "foreign"),
+		outer_helper(false),
+	}
+
+	check_round_trip(t, "list", err.Error())
+
+	reports, _ := ParseReports(strings.NewReader(err.Error()))
+	if len(reports) != 3 || len(reports[0].Causes) != 3 || !reports[0].Causes[1].Foreign || reports[1].Detail != "unexpected EOF" {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	if g := reports[0].Causes[0].Causes[0].Goroutines; len(g) != 4 || g[2].ID != 19 || g[2].CreatedBy.Function != "New" {
+		t.Errorf("unexpected goroutines: %+v", g)
+	}
+	if reports[2].Shared != 1 {
+		t.Errorf("expected the shared frames to be parsed")
+	}
+}
+
+func check_round_trip(t *testing.T, name, text string) {
+	reports, err := ParseReports(strings.NewReader(text))
+	if err != nil {
+		t.Errorf("%s: %s", name, err)
+		return
+	}
+
+	s := make([]string, len(reports))
+	for i, r := range reports {
+		s[i] = r.String()
+	}
+
+	if out := strings.Join(s, "\n"); out != text {
+		t.Errorf("%s: expected the report to round-trip, got:\n%s", name, out)
+	}
+}
+
+func read_file(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
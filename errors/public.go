@@ -0,0 +1,80 @@
+package errors
+
+import (
+	"fmt"
+)
+
+var kind_messages = map[Kind]string{
+	KindNotFound:        "not found",
+	KindConflict:        "conflict",
+	KindInvalidArgument: "invalid argument",
+	KindUnauthenticated: "unauthenticated",
+	KindTimeout:         "timed out",
+	KindUnavailable:     "temporarily unavailable",
+}
+
+// Set the message which may be shown to end users (without internal
+// details).
+func (e *Error) WithPublic(message string, args ...interface{}) *Error {
+	if e == nil {
+		return nil
+	}
+
+	e.public = fmt.Sprintf(message, args...)
+	return e
+}
+
+// The public message of this error (without looking at its causes; see
+// PublicMessage)
+func (e *Error) Public() string {
+	if e == nil {
+		return ""
+	}
+
+	return e.public
+}
+
+// The outermost public message in the annotation chain of err ("" when
+// there is none). Lists report the public message of their first error
+// which has one.
+func PublicMessage(err error) string {
+	switch e := err.(type) {
+
+	case *Error:
+		if e == nil {
+			return ""
+		}
+		if e.public != "" {
+			return e.public
+		}
+		return PublicMessage(e.err)
+
+	case List:
+		for _, err := range e {
+			if msg := PublicMessage(err); msg != "" {
+				return msg
+			}
+		}
+
+	}
+
+	return ""
+}
+
+// The message for end users: the public message of err or a generic
+// message for the kind of err.
+func SafeMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if msg := PublicMessage(err); msg != "" {
+		return msg
+	}
+
+	if msg, found := kind_messages[KindOf(err)]; found {
+		return msg
+	}
+
+	return "internal error"
+}
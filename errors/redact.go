@@ -0,0 +1,99 @@
+package errors
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// Replaces the redacted context values
+const Redacted = "[REDACTED]"
+
+// Context keys which are redacted by the renderers, the JSON encoding and
+// RedactedFields. The patterns are matched with path.Match against the key
+// and each of its segments (separated by / or .), ignoring case and
+// treating - like _. So auth/token and db.password are redacted too.
+//
+// Only context values are redacted. Secrets in the message of an error or
+// in AddContext text without an = (which becomes the key) are never
+// redacted.
+var RedactKeys = []string{
+	"*password*", "*passwd*", "*secret*", "*token*", "*api_key*", "*apikey*",
+	"authorization", "cookie", "set-cookie",
+}
+
+// A context value which is always redacted (like an email address). The
+// value itself is only available through the Value field.
+type Sensitive struct {
+	Value interface{}
+}
+
+func (s Sensitive) String() string {
+	return Redacted
+}
+
+func (s Sensitive) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+// The context fields of the first *Error in the chain of err (like Fields)
+// with the sensitive values redacted.
+func RedactedFields(err error) map[string]interface{} {
+	fields := Fields(err)
+
+	for key, value := range fields {
+		fields[key] = redact(key, value)
+	}
+
+	return fields
+}
+
+func redact(key string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	if _, ok := value.(Sensitive); ok {
+		return Redacted
+	}
+
+	if redacted_key(key) {
+		return Redacted
+	}
+
+	return value
+}
+
+func redacted_key(key string) bool {
+	key = normalize_key(key)
+
+	segments := strings.FieldsFunc(key, func(r rune) bool { return r == '/' || r == '.' })
+	segments = append(segments, key)
+
+	for _, pattern := range RedactKeys {
+		pattern = normalize_key(pattern)
+
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func redact_fields(fields []field_t) []field_t {
+	o := make([]field_t, len(fields))
+
+	for i, f := range fields {
+		f.value = redact(f.key, f.value)
+		o[i] = f
+	}
+
+	return o
+}
+
+func normalize_key(key string) string {
+	return strings.Replace(strings.ToLower(key), "-", "_", -1)
+}
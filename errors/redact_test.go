@@ -0,0 +1,94 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPublicMessage(t *testing.T) {
+	inner := New("db: relation users does not exist").WithPublic("Your account could not be loaded")
+	outer := Annotate(inner, "load user %d", 7)
+
+	if PublicMessage(outer) != "Your account could not be loaded" || outer.Public() != "" {
+		t.Errorf("expected the public message of the cause, got %q", PublicMessage(outer))
+	}
+
+	outer.WithPublic("Try again later")
+	if PublicMessage(outer) != "Try again later" {
+		t.Error("expected the outermost public message to win")
+	}
+
+	tests := []struct {
+		err error
+		msg string
+	}{
+		{nil, ""},
+		{outer, "Try again later"},
+		{List{io.EOF, inner}, "Your account could not be loaded"},
+		{Annotate(NotFound("row 7"), "query"), "not found"},
+		{io.EOF, "internal error"},
+	}
+
+	for _, test := range tests {
+		if msg := SafeMessage(test.err); msg != test.msg {
+			t.Errorf("%v: expected %q, got %q", test.err, test.msg, msg)
+		}
+	}
+
+	if !strings.Contains(outer.Error(), "  public: Try again later\n") {
+		t.Errorf("expected the public message to be rendered:\n%s", outer)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	// (split to keep the source context from leaking them)
+	secrets := []string{"bob" + "@example.com", "k-" + "123", "hunter" + "2"}
+
+	err := New("login failed")
+	err.With("user", "bob")
+	err.With("email", Sensitive{secrets[0]})
+	err.With("X-Api-Key", secrets[1])
+	err.AddContext("password=%s", secrets[2])
+
+	for _, s := range []string{
+		err.Error(),
+		RenderString(Markdown, err),
+		RenderString(HTML, err),
+		fmt.Sprintf("%#v", err),
+		fmt.Sprint(RedactedFields(err)),
+		fmt.Sprint(err.Fields()["email"]),
+	} {
+		for _, secret := range secrets {
+			if strings.Contains(s, secret) {
+				t.Errorf("expected %q to be redacted:\n%s", secret, s)
+			}
+		}
+	}
+
+	data, _ := json.Marshal(err)
+	if strings.Contains(string(data), secrets[2]) || strings.Contains(string(data), secrets[0]) {
+		t.Errorf("expected the JSON to be redacted: %s", data)
+	}
+
+	fields := RedactedFields(err)
+	if fields["user"] != "bob" || fields["password"] != Redacted || fields["email"] != Redacted {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if s, ok := err.Fields()["email"].(Sensitive); !ok || s.Value != secrets[0] {
+		t.Error("expected the raw value to stay available")
+	}
+
+	for _, key := range []string{"auth/token", "db.Password", "Set-Cookie"} {
+		if !redacted_key(key) {
+			t.Errorf("expected %s to be redacted", key)
+		}
+	}
+	for _, key := range []string{"user", "auth/user", "db.host"} {
+		if redacted_key(key) {
+			t.Errorf("expected %s not to be redacted", key)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Renderer writes the full report of an error (with context, stacks and
+// causes).
+type Renderer interface {
+	Render(w io.Writer, err error) error
+}
+
+var (
+	// The multi-line text report
+	Plain Renderer = text_renderer_t{}
+
+	// The text report colored with ANSI escape codes (for terminals)
+	ANSI Renderer = text_renderer_t{color: true}
+
+	// A Markdown report (for chat and issue trackers)
+	Markdown Renderer = markdown_renderer_t{}
+
+	// A self-contained HTML page (for development servers)
+	HTML Renderer = html_renderer_t{}
+)
+
+// The Renderer used by Error() and %+v in FullMode
+var DefaultRenderer = Plain
+
+// Render err with r into a string.
+func RenderString(r Renderer, err error) string {
+	var buf bytes.Buffer
+	r.Render(&buf, err)
+	return buf.String()
+}
+
+// The parts of an error which are rendered
+type report_t struct {
+	message    string
+	detail     string // the message of a cause which is not an Error or List
+	public     string
+	kind       Kind
+	context    []field_t
+	stack      Stack // the frames to render (with source context)
+	shared     int   // the number of frames left out because the cause renders them
+	goroutines []Goroutine
+	cause      *report_t
+	list       []*report_t // the errors of a List
+	foreign    bool        // an error from another package (only message is set)
+}
+
+func new_report(err error) *report_t {
+	switch e := err.(type) {
+
+	case *Error:
+		if e == nil {
+			return &report_t{foreign: true, message: "(no error)"}
+		}
+
+		r := &report_t{
+			message:    e.message,
+			public:     e.public,
+			kind:       e.kind,
+			context:    redact_fields(sorted_fields(e.context)),
+			goroutines: e.goroutines,
+		}
+
+		switch e.err.(type) {
+		case nil:
+		case *Error, List:
+			r.cause = new_report(e.err)
+		default:
+			r.detail = e.err.Error()
+		}
+
+		if stack := e.Stack(); len(stack) > 0 {
+			// the frames shared with the cause are rendered by the cause
+			if c, ok := e.err.(*Error); ok {
+				r.shared = common_frames(stack, c.Stack())
+			}
+
+			if r.shared == len(stack) {
+				// annotated on the line which made the cause; just mark the frame
+				r.shared--
+				r.stack = stack[:1]
+			} else {
+				r.stack = stack[:len(stack)-r.shared].Limit(StackLimit).WithContext()
+			}
+		}
+
+		return r
+
+	case List:
+		r := &report_t{list: make([]*report_t, len(e))}
+		for i, err := range e {
+			r.list[i] = new_report(err)
+		}
+		return r
+
+	default:
+		return &report_t{foreign: true, message: err.Error()}
+
+	}
+}
+
+func (r *report_t) shared_string() string {
+	switch r.shared {
+	case 0:
+		return ""
+	case 1:
+		return "... 1 frame shared with cause"
+	default:
+		return fmt.Sprintf("... %d frames shared with cause", r.shared)
+	}
+}
+
+// The classes of text which are styled
+const (
+	style_label = iota
+	style_message
+	style_key
+	style_location
+	style_function
+	style_current
+	style_dim
+)
+
+type style_t func(class int, s string) string
+
+func plain_style(class int, s string) string {
+	return s
+}
+
+var ansi_codes = map[int]string{
+	style_label:    "\x1b[1;31m",
+	style_message:  "\x1b[1m",
+	style_key:      "\x1b[36m",
+	style_location: "\x1b[34m",
+	style_function: "\x1b[33m",
+	style_current:  "\x1b[1m",
+	style_dim:      "\x1b[2m",
+}
+
+func ansi_style(class int, s string) string {
+	if s == "" {
+		return s
+	}
+
+	return ansi_codes[class] + s + "\x1b[0m"
+}
+
+type text_renderer_t struct {
+	color bool
+}
+
+func (t text_renderer_t) Render(w io.Writer, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	_, e := io.WriteString(w, t.text(new_report(err)))
+	return e
+}
+
+func (t text_renderer_t) text(r *report_t) string {
+	var (
+		buf   bytes.Buffer
+		style = plain_style
+	)
+
+	if t.color {
+		style = ansi_style
+	}
+
+	if r.foreign {
+		return r.message
+	}
+
+	if r.list != nil {
+		s := make([]string, len(r.list))
+		for i, r := range r.list {
+			s[i] = t.text(r)
+		}
+		return strings.Join(s, "\n")
+	}
+
+	fmt.Fprintf(&buf, "%s %s\n", style(style_label, "error:"), style(style_message, r.message))
+
+	if r.detail != "" {
+		fmt.Fprintf(&buf, "  %s %s\n", style(style_label, "message:"), r.detail)
+	}
+
+	if r.public != "" {
+		fmt.Fprintf(&buf, "  %s %s\n", style(style_label, "public:"), r.public)
+	}
+
+	if r.kind != KindUnknown {
+		fmt.Fprintf(&buf, "  %s %s\n", style(style_label, "kind:"), r.kind)
+	}
+
+	if len(r.context) > 0 {
+		fmt.Fprintf(&buf, "  %s\n", style(style_label, "context:"))
+
+		width := 0
+		for _, f := range r.context {
+			if len(f.key) > width {
+				width = len(f.key)
+			}
+		}
+
+		for _, f := range r.context {
+			key := style(style_key, f.key) + strings.Repeat(" ", width+1-len(f.key))
+			if !f.has_value {
+				fmt.Fprintf(&buf, "    %s\n", key)
+			} else {
+				fmt.Fprintf(&buf, "    %s= %v\n", key, f.value)
+			}
+		}
+	}
+
+	if len(r.stack) > 0 {
+		var stack bytes.Buffer
+		r.stack.write_to(&stack, style)
+		if s := r.shared_string(); s != "" {
+			fmt.Fprintln(&stack, style(style_dim, s))
+		}
+
+		lines := strings.TrimSuffix(stack.String(), "\n")
+		lines = strings.Replace(lines, "\n", "\n    ", -1)
+		fmt.Fprintf(&buf, "  %s\n    %s\n", style(style_label, "location:"), lines)
+	}
+
+	if len(r.goroutines) > 0 {
+		var dump bytes.Buffer
+		write_goroutines(&dump, r.goroutines)
+		s := strings.TrimSuffix(dump.String(), "\n")
+		s = strings.Replace(s, "\n", "\n    ", -1)
+		fmt.Fprintf(&buf, "  %s\n    %s\n", style(style_label, "goroutines:"), s)
+	}
+
+	if r.cause != nil {
+		s := t.text(r.cause)
+		s = strings.TrimSpace(s)
+		s = strings.Replace(s, "\n", "\n  ", -1)
+		fmt.Fprintf(&buf, "  %s\n", s)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,141 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"strconv"
+)
+
+type html_renderer_t struct{}
+
+const html_style = `
+body { font: 14px/1.4 -apple-system, sans-serif; margin: 2em; color: #222; }
+section.error { border-left: 4px solid #c33; padding: 0 0 0 1em; margin: 1em 0; }
+h1 { font-size: 1.3em; margin: 0 0 .5em; }
+h1 .label { color: #c33; }
+p { margin: .3em 0; }
+table { border-collapse: collapse; margin: .5em 0; }
+th, td { text-align: left; padding: .1em 1em .1em 0; font-family: monospace; vertical-align: top; }
+th { color: #066; font-weight: normal; }
+.frame { margin: .2em 0; font-family: monospace; }
+.frame summary { cursor: pointer; }
+.function { color: #960; }
+.location, .dim { color: #888; }
+pre { background: #f6f6f6; margin: .3em 0 .6em; padding: .5em; overflow: auto; }
+pre .current { background: #fdd; display: block; }
+`
+
+func (h html_renderer_t) Render(w io.Writer, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>error: %s</title>\n<style>%s</style>\n</head>\n<body>\n",
+		html.EscapeString(short_string(err)), html_style)
+	h.write(&buf, new_report(err))
+	fmt.Fprintln(&buf, "</body>\n</html>")
+
+	_, e := w.Write(buf.Bytes())
+	return e
+}
+
+func (h html_renderer_t) write(buf *bytes.Buffer, r *report_t) {
+	if r.foreign {
+		fmt.Fprintf(buf, "<section class=\"error\"><h1><span class=\"label\">error:</span> %s</h1></section>\n", html.EscapeString(r.message))
+		return
+	}
+
+	if r.list != nil {
+		for _, r := range r.list {
+			h.write(buf, r)
+		}
+		return
+	}
+
+	fmt.Fprintf(buf, "<section class=\"error\">\n<h1><span class=\"label\">error:</span> %s</h1>\n", html.EscapeString(r.message))
+
+	if r.detail != "" {
+		fmt.Fprintf(buf, "<p class=\"detail\">%s</p>\n", html.EscapeString(r.detail))
+	}
+
+	if r.public != "" {
+		fmt.Fprintf(buf, "<p class=\"public\">public: %s</p>\n", html.EscapeString(r.public))
+	}
+
+	if r.kind != KindUnknown {
+		fmt.Fprintf(buf, "<p class=\"kind\">kind: <code>%s</code></p>\n", r.kind)
+	}
+
+	if len(r.context) > 0 {
+		fmt.Fprintln(buf, "<table class=\"context\">")
+		for _, f := range r.context {
+			value := ""
+			if f.has_value {
+				value = html.EscapeString(fmt.Sprint(f.value))
+			}
+			fmt.Fprintf(buf, "<tr><th>%s</th><td>%s</td></tr>\n", html.EscapeString(f.key), value)
+		}
+		fmt.Fprintln(buf, "</table>")
+	}
+
+	for i := range r.stack {
+		h.write_frame(buf, &r.stack[i], i == 0)
+	}
+
+	if s := r.shared_string(); s != "" {
+		fmt.Fprintf(buf, "<p class=\"dim\">%s</p>\n", html.EscapeString(s))
+	}
+
+	if len(r.goroutines) > 0 {
+		var dump bytes.Buffer
+		write_goroutines(&dump, r.goroutines)
+		fmt.Fprintf(buf, "<details class=\"goroutines\">\n<summary>%d goroutines</summary>\n<pre>%s</pre>\n</details>\n",
+			len(r.goroutines), html.EscapeString(dump.String()))
+	}
+
+	if r.cause != nil {
+		h.write(buf, r.cause)
+	}
+
+	fmt.Fprintln(buf, "</section>")
+}
+
+// frames with source context can be expanded (the first one is)
+func (h html_renderer_t) write_frame(buf *bytes.Buffer, f *StackFrame, open bool) {
+	summary := fmt.Sprintf("<span class=\"function\">%s.%s()</span> <span class=\"location\">%s:%d</span>",
+		html.EscapeString(path.Base(f.Package)), html.EscapeString(f.FuncName()), html.EscapeString(f.Location()), f.Line)
+
+	if !f.HasContext {
+		fmt.Fprintf(buf, "<div class=\"frame\">%s</div>\n", summary)
+		return
+	}
+
+	attr := ""
+	if open {
+		attr = " open"
+	}
+
+	fmt.Fprintf(buf, "<details class=\"frame\"%s>\n<summary>%s</summary>\n<pre>", attr, summary)
+
+	var (
+		s     = f.Line - len(f.PreContext)
+		width = len(strconv.Itoa(f.Line + len(f.PostContext)))
+	)
+
+	for i, line := range f.PreContext {
+		fmt.Fprintln(buf, html.EscapeString(context_line_string(" ", s+i, width, line)))
+	}
+
+	fmt.Fprintf(buf, "<span class=\"current\">%s</span>", html.EscapeString(context_line_string(">", f.Line, width, f.ContextLine)))
+
+	for i, line := range f.PostContext {
+		fmt.Fprintln(buf, html.EscapeString(context_line_string(" ", f.Line+1+i, width, line)))
+	}
+
+	fmt.Fprintln(buf, "</pre>\n</details>")
+}
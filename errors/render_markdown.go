@@ -0,0 +1,140 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type markdown_renderer_t struct{}
+
+func (m markdown_renderer_t) Render(w io.Writer, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	m.write(&buf, new_report(err))
+
+	_, e := w.Write(buf.Bytes())
+	return e
+}
+
+func (m markdown_renderer_t) write(buf *bytes.Buffer, r *report_t) {
+	if r.foreign {
+		fmt.Fprintf(buf, "%s\n", markdown_escape(r.message))
+		return
+	}
+
+	if r.list != nil {
+		for i, r := range r.list {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			m.write(buf, r)
+		}
+		return
+	}
+
+	fmt.Fprintf(buf, "**error:** %s\n", markdown_escape(r.message))
+
+	if r.detail != "" {
+		fmt.Fprintf(buf, "- **message:** %s\n", markdown_escape(r.detail))
+	}
+
+	if r.public != "" {
+		fmt.Fprintf(buf, "- **public:** %s\n", markdown_escape(r.public))
+	}
+
+	if r.kind != KindUnknown {
+		fmt.Fprintf(buf, "- **kind:** %s\n", markdown_code(r.kind.String()))
+	}
+
+	if len(r.context) > 0 {
+		fmt.Fprintln(buf, "- **context:**")
+		for _, f := range r.context {
+			if !f.has_value {
+				fmt.Fprintf(buf, "  - %s\n", markdown_code(f.key))
+			} else {
+				fmt.Fprintf(buf, "  - %s = %s\n", markdown_code(f.key), markdown_code(fmt.Sprint(f.value)))
+			}
+		}
+	}
+
+	if len(r.stack) > 0 {
+		s := r.stack.String()
+		if shared := r.shared_string(); shared != "" {
+			s += "\n" + shared
+		}
+		fmt.Fprintf(buf, "\n%s\n", markdown_code_block(s))
+	}
+
+	if len(r.goroutines) > 0 {
+		var dump bytes.Buffer
+		write_goroutines(&dump, r.goroutines)
+		fmt.Fprintf(buf, "\n**goroutines:**\n\n%s\n", markdown_code_block(strings.TrimSuffix(dump.String(), "\n")))
+	}
+
+	if r.cause != nil {
+		var cause bytes.Buffer
+		m.write(&cause, r.cause)
+
+		buf.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSuffix(cause.String(), "\n"), "\n") {
+			if line == "" {
+				buf.WriteString(">\n")
+			} else {
+				fmt.Fprintf(buf, "> %s\n", line)
+			}
+		}
+	}
+}
+
+var markdown_escaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`, `~`, `\~`,
+)
+
+func markdown_escape(s string) string {
+	return markdown_escaper.Replace(s)
+}
+
+// an inline code span which may contain backticks
+func markdown_code(s string) string {
+	fence := strings.Repeat("`", longest_run(s, '`')+1)
+
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+
+	return fence + s + fence
+}
+
+func markdown_code_block(s string) string {
+	n := longest_run(s, '`') + 1
+	if n < 3 {
+		n = 3
+	}
+
+	fence := strings.Repeat("`", n)
+	return fence + "\n" + s + "\n" + fence
+}
+
+func longest_run(s string, c byte) int {
+	var longest, n int
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			n = 0
+			continue
+		}
+
+		n++
+		if n > longest {
+			longest = n
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,62 @@
+package errors
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRenderers(t *testing.T) {
+	inner := NotFound("user <%s>", "bob")
+	inner.AddContext("id=7")
+
+	err := Annotate(inner, "load *profile*")
+	err.With("note", "a`b")
+
+	plain := RenderString(Plain, err)
+	if plain != err.Error() {
+		t.Errorf("expected Plain to render Error():\n%s", plain)
+	}
+
+	ansi := RenderString(ANSI, err)
+	if !strings.Contains(ansi, "\x1b[1;31merror:\x1b[0m") {
+		t.Errorf("expected colors:\n%q", ansi)
+	}
+	if s := regexp.MustCompile("\x1b\\[[0-9;]*m").ReplaceAllString(ansi, ""); s != plain {
+		t.Errorf("expected ANSI to match Plain without colors:\n%s", s)
+	}
+
+	markdown := RenderString(Markdown, err)
+	for _, s := range []string{
+		"**error:** load \\*profile\\*\n",
+		"  - `note` = ``a`b``\n",
+		"\n```\ngithub.com/fd/go-util/errors/render_test.go:",
+		"\n> **error:** user \\<bob\\>\n> - **kind:** `not_found`\n",
+	} {
+		if !strings.Contains(markdown, s) {
+			t.Errorf("expected %q in:\n%s", s, markdown)
+		}
+	}
+
+	page := RenderString(HTML, err)
+	for _, s := range []string{
+		"<title>error: load *profile*: user &lt;bob&gt;</title>",
+		"<h1><span class=\"label\">error:</span> user &lt;bob&gt;</h1>",
+		"<tr><th>note</th><td>a`b</td></tr>",
+		"<details class=\"frame\" open>\n<summary><span class=\"function\">errors.TestRenderers()</span>",
+		"<span class=\"current\">&gt; ",
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("expected %q in:\n%s", s, page)
+		}
+	}
+	if strings.Count(page, "<section") != strings.Count(page, "</section>") {
+		t.Errorf("expected balanced sections:\n%s", page)
+	}
+
+	defer func(r Renderer) { DefaultRenderer = r }(DefaultRenderer)
+	DefaultRenderer = Markdown
+	if err.Error() != markdown {
+		t.Error("expected Error() to use the DefaultRenderer")
+	}
+}
@@ -0,0 +1,159 @@
+package errors
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// A source of time for Retry (replaced by a fake clock in tests)
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// The Clock backed by the time package
+var SystemClock Clock = system_clock_t{}
+
+type system_clock_t struct{}
+
+func (system_clock_t) Now() time.Time                         { return time.Now() }
+func (system_clock_t) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type RetryPolicy struct {
+	// The maximum number of attempts (0 means no limit)
+	MaxAttempts int
+
+	// The delay before the second attempt (defaults to 100ms)
+	InitialBackoff time.Duration
+
+	// The maximum delay between attempts (defaults to 10s)
+	MaxBackoff time.Duration
+
+	// The factor by which the delay grows after each attempt (defaults to 2)
+	Multiplier float64
+
+	// The fraction (0 to 1) of each delay which is randomized
+	Jitter float64
+
+	// Stop retrying once this much time has passed (0 means no limit)
+	MaxElapsed time.Duration
+
+	// Defaults to SystemClock
+	Clock Clock
+}
+
+// Call f until it succeeds. Retrying stops when f returns a fatal error
+// or a non-fatal error of a permanent kind, when the attempts or time of
+// the policy run out or when ctx is done. The returned *Error wraps a List of
+// the errors of all attempts and has the attempts and elapsed context
+// fields.
+func Retry(ctx context.Context, policy RetryPolicy, f func() error) error {
+	policy = policy.defaults()
+
+	var (
+		clock    = policy.Clock
+		start    = clock.Now()
+		backoff  = policy.InitialBackoff
+		attempts List
+		canceled bool
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			attempts = append(attempts, err)
+			canceled = true
+			break
+		}
+
+		err := f()
+		if err == nil {
+			return nil
+		}
+
+		attempts = append(attempts, err)
+
+		if !retryable(err) {
+			break
+		}
+
+		if policy.MaxAttempts > 0 && len(attempts) >= policy.MaxAttempts {
+			break
+		}
+
+		delay := policy.jitter(backoff)
+		if backoff = time.Duration(float64(backoff) * policy.Multiplier); backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+
+		now := clock.Now()
+		if policy.MaxElapsed > 0 && now.Add(delay).Sub(start) > policy.MaxElapsed {
+			break
+		}
+		if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			attempts = append(attempts, ctx.Err())
+			canceled = true
+		case <-clock.After(delay):
+			continue
+		}
+
+		break
+	}
+
+	// the error of a done ctx is not an attempt
+	n := len(attempts)
+	if canceled {
+		n--
+	}
+
+	e := new_error(1, attempts, "gave up after %d attempts", []interface{}{n})
+	e.fatal = IsFatal(attempts[len(attempts)-1])
+
+	e.With("attempts", n)
+	e.With("elapsed", clock.Now().Sub(start))
+
+	return e
+}
+
+func retryable(err error) bool {
+	if IsFatal(err) {
+		return false
+	}
+
+	kind := KindOf(err)
+	return kind == KindUnknown || kind.Temporary()
+}
+
+func (p RetryPolicy) defaults() RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	} else if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	if p.Clock == nil {
+		p.Clock = SystemClock
+	}
+	return p
+}
+
+func (p RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter == 0 {
+		return d
+	}
+
+	return d - time.Duration(float64(d)*p.Jitter*rand.Float64())
+}
@@ -0,0 +1,168 @@
+package errors
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+type fake_clock_t struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fake_clock_t) Now() time.Time {
+	return c.now
+}
+
+func (c *fake_clock_t) After(d time.Duration) <-chan time.Time {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestRetry(t *testing.T) {
+	var (
+		clock  = &fake_clock_t{now: time.Unix(0, 0)}
+		policy = RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 3 * time.Second, Clock: clock}
+		calls  int
+	)
+
+	err := Retry(context.Background(), policy, func() error {
+		calls++
+		if calls < 4 {
+			return Unavailable("down")
+		}
+		return nil
+	})
+
+	if err != nil || calls != 4 {
+		t.Fatalf("expected success after 4 calls, got %d: %v", calls, err)
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if len(clock.sleeps) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, clock.sleeps)
+	}
+	for i, d := range expected {
+		if clock.sleeps[i] != d {
+			t.Errorf("expected %v, got %v", expected, clock.sleeps)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var (
+		clock  = &fake_clock_t{now: time.Unix(0, 0)}
+		policy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, Clock: clock}
+		calls  int
+	)
+
+	err := Retry(context.Background(), policy, func() error {
+		calls++
+		e := New("attempt %d", calls)
+		e.SetFatal(false)
+		return e
+	})
+
+	e, ok := err.(*Error)
+	if !ok || calls != 3 {
+		t.Fatalf("expected an *Error after 3 calls, got %d: %v", calls, err)
+	}
+
+	fields := e.Fields()
+	if fields["attempts"] != 3 || fields["elapsed"] != 3*time.Second {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+
+	if l, ok := e.Unwrap().(List); !ok || len(l) != 3 {
+		t.Errorf("expected a List of 3 errors, got %#v", e.Unwrap())
+	}
+
+	if e.Stack()[0].Function != "TestRetryGivesUp" {
+		t.Errorf("expected the stack to start at the caller, got %s", e.Stack()[0].FuncName())
+	}
+}
+
+func TestRetryStopsOnFatal(t *testing.T) {
+	var (
+		clock = &fake_clock_t{now: time.Unix(0, 0)}
+		calls int
+	)
+
+	err := Retry(context.Background(), RetryPolicy{Clock: clock}, func() error {
+		calls++
+		return io.EOF
+	})
+
+	if calls != 1 || !Is(err, io.EOF) || !IsFatal(err) {
+		t.Errorf("expected to stop after the first fatal error, got %d: %v", calls, err)
+	}
+}
+
+func TestRetryFatalWins(t *testing.T) {
+	var (
+		clock = &fake_clock_t{now: time.Unix(0, 0)}
+		calls int
+	)
+
+	err := Retry(context.Background(), RetryPolicy{Clock: clock}, func() error {
+		calls++
+		e := Timeout("slow")
+		e.SetFatal(true)
+		return e
+	})
+
+	if calls != 1 || !IsFatal(err) {
+		t.Errorf("expected a fatal temporary error to stop retrying, got %d: %v", calls, err)
+	}
+
+	calls = 0
+	err = Retry(context.Background(), RetryPolicy{Clock: clock}, func() error {
+		calls++
+		e := NotFound("missing")
+		e.SetFatal(false)
+		return e
+	})
+
+	if calls != 1 || err == nil {
+		t.Errorf("expected a permanent kind to stop retrying, got %d: %v", calls, err)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	var (
+		clock  = &fake_clock_t{now: time.Unix(0, 0)}
+		policy = RetryPolicy{InitialBackoff: time.Second, MaxElapsed: 5 * time.Second, Clock: clock}
+		calls  int
+	)
+
+	err := Retry(context.Background(), policy, func() error {
+		calls++
+		return Timeout("slow")
+	})
+
+	// 1s + 2s fit in 5s but the next 4s delay doesn't
+	if calls != 3 || err == nil {
+		t.Errorf("expected 3 calls, got %d: %v", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls = 0
+	err = Retry(ctx, policy, func() error { calls++; return nil })
+	if calls != 0 || !Is(err, context.Canceled) {
+		t.Errorf("expected a canceled context to stop retrying, got %d: %v", calls, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	err = Retry(ctx, policy, func() error { cancel(); return ctx.Err() })
+	if e, ok := err.(*Error); !ok || e.Fields()["attempts"] != 1 {
+		t.Errorf("expected the error returned by f to count as an attempt, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package errors
+
+import (
+	"reflect"
+	"strings"
+)
+
+// StackRules decide which frames belong to the application and which frames
+// are dropped from the stacks of errors.
+type StackRules struct {
+	// Package path prefixes of in-app frames. When empty the main module
+	// (from debug.ReadBuildInfo) is used.
+	InApp []string
+
+	// Package path prefixes of the frames dropped from the stacks of errors.
+	// Frames of the errors package itself (other than its tests) are always
+	// dropped.
+	Drop []string
+
+	// Render consecutive library (not in-app) frames as a single line
+	CollapseLibrary bool
+}
+
+// The rules used when capturing and rendering stacks
+var Rules = StackRules{
+	Drop: []string{"runtime", "testing"},
+}
+
+var self_package = reflect.TypeOf(Error{}).PkgPath()
+
+func (r *StackRules) in_app(frame *StackFrame) bool {
+	if len(r.InApp) > 0 {
+		return has_package_prefix(frame.Package, r.InApp)
+	}
+
+	if main, _ := build_modules(); main != "" {
+		return frame.Package == "main" || has_package_prefix(frame.Package, []string{main})
+	}
+
+	// without build info assume everything outside the standard library
+	// belongs to the application
+	first := frame.Package
+	if i := strings.IndexByte(first, '/'); i >= 0 {
+		first = first[:i]
+	}
+	return strings.Contains(first, ".")
+}
+
+func (r *StackRules) drop(frame *StackFrame) bool {
+	if frame.Package == self_package && !strings.HasSuffix(frame.Filename, "_test.go") {
+		return true
+	}
+
+	return has_package_prefix(frame.Package, r.Drop)
+}
+
+// pkg is one of the prefixes or a sub package of one of them.
+func has_package_prefix(pkg string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,16 +3,14 @@
 package sentry
 
 import (
-	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"path"
 	"runtime"
-	"strings"
 	"time"
+
+	"github.com/fd/go-util/errors"
 )
 
 var (
@@ -26,43 +24,36 @@ func init() {
 }
 
 type Packet struct {
-	EventID    string                 `json:"event_id,omitempty"`
-	Message    string                 `json:"message,omitempty"` // max 1000
-	Timestamp  time.Time              `json:"timestamp,omitempty"`
-	Level      LogLevel               `json:"level,omitempty"`
-	Logger     string                 `json:"logger,omitempty"`
-	Platform   string                 `json:"platform,omitempty"`
-	Culprit    string                 `json:"culprit,omitempty"`
-	Tags       Tags                   `json:"tags,omitempty"`
-	ServerName string                 `json:"server_name,omitempty"`
-	Modules    []map[string]string    `json:"modules,omitempty"`
-	Extra      map[string]interface{} `json:"extra,omitempty"`
+	EventID     string                 `json:"event_id,omitempty"`
+	Message     string                 `json:"message,omitempty"` // max 1000
+	Timestamp   time.Time              `json:"timestamp,omitempty"`
+	Level       LogLevel               `json:"level,omitempty"`
+	Logger      string                 `json:"logger,omitempty"`
+	Platform    string                 `json:"platform,omitempty"`
+	Culprit     string                 `json:"culprit,omitempty"`
+	Tags        Tags                   `json:"tags,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Modules     []map[string]string    `json:"modules,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Fingerprint []string               `json:"fingerprint,omitempty"`
 
 	Stacktrace struct {
 		Frames []*stack_frame_t `json:"frames"`
 	} `json:"stacktrace,omitempty"`
 }
 
-/*
-
 type stack_frame_t struct {
-  Filename    string   `json:"filename"`
-  Function    string   `json:"function"`
-  Module      string   `json:"module"`
-  Line        int      `json:"lineno"`
-  AbsPath     string   `json:"abs_path"`
-  PreContext  []string `json:"pre_context"`
-  ContextLine string   `json:"context_line"`
-  PostContext []string `json:"post_context"`
-  InApp       bool     `json:"in_app"`
+	Filename    string   `json:"filename"`
+	Function    string   `json:"function"`
+	Module      string   `json:"module"`
+	Line        int      `json:"lineno"`
+	AbsPath     string   `json:"abs_path"`
+	PreContext  []string `json:"pre_context"`
+	ContextLine string   `json:"context_line"`
+	PostContext []string `json:"post_context"`
+	InApp       bool     `json:"in_app"`
 }
 
-  for i, j := 0, len(stack)/2; i < j; i++ {
-    k := len(stack) - i - 1
-    stack[i], stack[k] = stack[k], stack[i]
-  }
-*/
-
 type LogLevel uint8
 
 type Tags [][2]string
@@ -108,6 +99,13 @@ func (packet *Packet) copy() *Packet {
 		p.Tags[i] = tag
 	}
 
+	p.Extra = make(map[string]interface{}, len(packet.Extra))
+	for key, value := range packet.Extra {
+		p.Extra[key] = value
+	}
+
+	p.Fingerprint = append([]string(nil), packet.Fingerprint...)
+
 	return p
 }
 
@@ -133,6 +131,69 @@ func (p *Packet) CaptureStack() {
 	p.Stacktrace.Frames = stack()
 }
 
+// Fill the packet from err. The message, stack and context fields are taken
+// from the first *errors.Error in the chain of err. Events are grouped by
+// the fingerprint of err. A nil err leaves the packet unchanged.
+func (p *Packet) CaptureError(err error) {
+	var e *errors.Error
+
+	if err == nil {
+		return
+	}
+
+	p.Fingerprint = []string{errors.Fingerprint(err)}
+
+	if !errors.As(err, &e) {
+		p.Message = err.Error()
+		p.CaptureStack()
+		return
+	}
+
+	p.Message = e.Message()
+	stack := e.Stack()
+	p.Stacktrace.Frames = frames(stack.WithContext())
+
+	// the culprit is the most recent in-app frame
+	for _, frame := range stack {
+		if frame.InApp {
+			p.Culprit = frame.Package + "." + frame.FuncName()
+			break
+		}
+	}
+
+	if p.Extra == nil {
+		p.Extra = make(map[string]interface{})
+	}
+	for key, value := range errors.RedactedFields(e) {
+		p.Extra[key] = value
+	}
+}
+
+func stack() []*stack_frame_t {
+	return frames(errors.CaptureStack().Skip(3).Filter())
+}
+
+// sentry expects the frames with the oldest call first
+func frames(stack errors.Stack) []*stack_frame_t {
+	o := make([]*stack_frame_t, len(stack))
+
+	for i, f := range stack {
+		o[len(stack)-1-i] = &stack_frame_t{
+			Filename:    f.Location(),
+			Function:    f.FuncName(),
+			Module:      f.Package,
+			Line:        f.Line,
+			AbsPath:     f.Filepath,
+			PreContext:  f.PreContext,
+			ContextLine: f.ContextLine,
+			PostContext: f.PostContext,
+			InApp:       f.InApp,
+		}
+	}
+
+	return o
+}
+
 func uuid4_str() string {
 	var (
 		u [16]uint8
@@ -0,0 +1,58 @@
+package sentry
+
+import (
+	"testing"
+
+	"github.com/fd/go-util/errors"
+)
+
+func TestCaptureError(t *testing.T) {
+	err := errors.Annotate(errors.NotFound("no user %d", 7), "load").
+		With("user", 7).
+		With("password", "hunter"+"2")
+
+	p := &Packet{}
+	p.CaptureError(err)
+
+	if p.Message != "load" {
+		t.Errorf("unexpected message: %q", p.Message)
+	}
+	if p.Extra["user"] != 7 || p.Extra["password"] != errors.Redacted {
+		t.Errorf("unexpected extra: %v", p.Extra)
+	}
+	if len(p.Fingerprint) != 1 || p.Fingerprint[0] != errors.Fingerprint(err) {
+		t.Errorf("unexpected fingerprint: %v", p.Fingerprint)
+	}
+
+	frames := p.Stacktrace.Frames
+	if len(frames) == 0 {
+		t.Fatal("expected a stack trace")
+	}
+	if f := frames[len(frames)-1]; f.Function != "TestCaptureError" || !f.InApp || f.ContextLine == "" {
+		t.Errorf("expected the most recent frame last, got %+v", f)
+	}
+	if p.Culprit != "github.com/fd/go-util/errors/sentry.TestCaptureError" {
+		t.Errorf("unexpected culprit: %q", p.Culprit)
+	}
+
+	p = &Packet{}
+	p.CaptureError(nil)
+	if p.Message != "" || p.Fingerprint != nil {
+		t.Errorf("expected a nil error to be ignored, got %+v", p)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	stack := errors.Stack{
+		{Package: "example.com/app", Function: "handle", InApp: true},
+		{Package: "net/http", Function: "serve"},
+	}
+
+	frames := frames(stack)
+	if len(frames) != 2 || frames[0].Function != "serve" || frames[1].Function != "handle" {
+		t.Fatalf("expected the oldest call first, got %+v %+v", frames[0], frames[1])
+	}
+	if frames[0].InApp || !frames[1].InApp {
+		t.Errorf("expected in_app to be kept, got %v %v", frames[0].InApp, frames[1].InApp)
+	}
+}
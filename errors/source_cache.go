@@ -0,0 +1,64 @@
+package errors
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"sync"
+)
+
+// The maximum number of source files kept in memory for rendering source
+// context.
+var SourceCacheSize = 64
+
+var source_files = &source_cache_t{
+	entries: map[string]*list.Element{},
+	lru:     list.New(),
+}
+
+// a concurrency-safe LRU cache of source files split into lines
+type source_cache_t struct {
+	mtx     sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type source_file_t struct {
+	path  string
+	lines [][]byte // nil when the file could not be read
+}
+
+func (c *source_cache_t) lines(path string) [][]byte {
+	c.mtx.Lock()
+	if elem, found := c.entries[path]; found {
+		c.lru.MoveToFront(elem)
+		lines := elem.Value.(*source_file_t).lines
+		c.mtx.Unlock()
+		return lines
+	}
+	c.mtx.Unlock()
+
+	// read the file without holding the lock
+	var lines [][]byte
+	if data, err := ioutil.ReadFile(path); err == nil {
+		lines = bytes.Split(data, []byte{'\n'})
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, found := c.entries[path]; found {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*source_file_t).lines
+	}
+
+	c.entries[path] = c.lru.PushFront(&source_file_t{path, lines})
+
+	for c.lru.Len() > SourceCacheSize && c.lru.Len() > 0 {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.entries, elem.Value.(*source_file_t).path)
+	}
+
+	return lines
+}
@@ -3,22 +3,24 @@ package errors
 import (
 	"bytes"
 	"fmt"
-	"io/ioutil"
 	"path"
 	"runtime"
+	"strconv"
 	"strings"
-	"text/tabwriter"
 )
 
 type Stack []StackFrame
 
 type StackFrame struct {
 	PC       uintptr
-	Package  string
-	Function string
-	Filepath string
-	Filename string
+	Module   string // module path, like github.com/fd/go-util (empty for the standard library)
+	Package  string // import path, like github.com/fd/go-util/errors
+	Receiver string // receiver type of methods, like *Error
+	Function string // function name, like Error or Guard.func1 for closures
+	Filepath string // absolute path of the source file
+	Filename string // path of the source file relative to the module, like errors/error.go
 	Line     int
+	Inlined  bool // the call was inlined into the next frame
 	InApp    bool
 
 	HasContext  bool
@@ -43,96 +45,211 @@ func (s Stack) Limit(n int) Stack {
 	return s[:n]
 }
 
+// A copy of the stack without the frames dropped by Rules
+func (s Stack) Filter() Stack {
+	o := make(Stack, 0, len(s))
+
+	for _, frame := range s {
+		if !Rules.drop(&frame) {
+			o = append(o, frame)
+		}
+	}
+
+	return o
+}
+
 func (s Stack) String() string {
 	var (
 		buf bytes.Buffer
 	)
 
-	s.write_to(&buf)
+	s.write_to(&buf, plain_style)
 	str := buf.String()
 	str = strings.TrimSuffix(str, "\n")
 
 	return str
 }
 
-func (s Stack) write_to(w *bytes.Buffer) {
+func (s Stack) write_to(w *bytes.Buffer, style style_t) {
+	for i := 0; i < len(s); i++ {
+		if !Rules.CollapseLibrary || s[i].InApp {
+			s[i].write_to(w, style)
+			continue
+		}
+
+		// collapse consecutive library frames
+		j := i
+		for j+1 < len(s) && !s[j+1].InApp {
+			j++
+		}
+
+		if i == j {
+			s[i].write_to(w, style)
+		} else {
+			line := fmt.Sprintf("... %d library frames (%s)", j-i+1, library_packages(s[i:j+1]))
+			fmt.Fprintln(w, style(style_dim, line))
+		}
+
+		i = j
+	}
+}
+
+// The number of frames at the end of a which are also at the end of b
+// (like the callers of both an error and the error it annotates).
+func common_frames(a, b Stack) int {
+	n := 0
+
+	for i, j := len(a)-1, len(b)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		x, y := &a[i], &b[j]
+		if x.Package != y.Package || x.Receiver != y.Receiver || x.Function != y.Function ||
+			x.Filepath != y.Filepath || x.Line != y.Line {
+			break
+		}
+		n++
+	}
+
+	return n
+}
+
+func library_packages(s Stack) string {
+	var pkgs []string
+
 	for _, frame := range s {
-		frame.write_to(w)
+		if len(pkgs) == 0 || pkgs[len(pkgs)-1] != frame.Package {
+			pkgs = append(pkgs, frame.Package)
+		}
 	}
+
+	return strings.Join(pkgs, ", ")
 }
 
-func (f *StackFrame) write_to(w *bytes.Buffer) {
-	fmt.Fprintf(w, "%s/%s:%d %s.%s() (0x%x)\n", f.Package, f.Filename, f.Line, path.Base(f.Package), f.Function, f.PC)
+func (f *StackFrame) write_to(w *bytes.Buffer, style style_t) {
+	fmt.Fprintf(w, "%s %s %s\n",
+		style(style_location, fmt.Sprintf("%s:%d", f.Location(), f.Line)),
+		style(style_function, path.Base(f.Package)+"."+f.FuncName()+"()"),
+		style(style_dim, fmt.Sprintf("(0x%x)", f.PC)))
 
 	if !f.HasContext {
 		return
 	}
 
 	var (
-		tw = tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
-		s  = f.Line - len(f.PreContext)
+		s     = f.Line - len(f.PreContext)
+		width = len(strconv.Itoa(f.Line + len(f.PostContext)))
 	)
 
 	for i, line := range f.PreContext {
-		fmt.Fprintf(tw, " \t%d\t%s\n", s+i, strings.Replace(line, "\t", "    ", -1))
+		fmt.Fprintln(w, style(style_dim, context_line_string(" ", s+i, width, line)))
 	}
 
-	fmt.Fprintf(tw, ">\t%d\t%s\n", f.Line, strings.Replace(f.ContextLine, "\t", "    ", -1))
+	fmt.Fprintln(w, style(style_current, context_line_string(">", f.Line, width, f.ContextLine)))
 
 	for i, line := range f.PostContext {
-		fmt.Fprintf(tw, " \t%d\t%s\n", f.Line+1+i, strings.Replace(line, "\t", "    ", -1))
+		fmt.Fprintln(w, style(style_dim, context_line_string(" ", f.Line+1+i, width, line)))
 	}
+}
 
-	tw.Flush()
+// like "> 21     err := f()" (with the line numbers aligned)
+func context_line_string(marker string, n, width int, line string) string {
+	return fmt.Sprintf("%s %-*d %s", marker, width, n, strings.Replace(line, "\t", "    ", -1))
 }
 
-func CaptureStack() Stack {
+// The program counters of a call stack. Callers are cheap to capture;
+// they are only resolved into a Stack when needed.
+type Callers []uintptr
+
+// Capture the program counters of the calling goroutine. skip is the number
+// of frames to skip, with 0 identifying the caller of CaptureCallers.
+func CaptureCallers(skip int) Callers {
 	var (
-		stack    []StackFrame
-		lines    [][]byte
-		lastFile string
+		buf [64]uintptr
+		pcs = buf[:]
 	)
 
-	for i := 0; ; i++ {
-		pc, file, line, ok := runtime.Caller(i)
-		if !ok {
-			break
+	for {
+		n := runtime.Callers(skip+2, pcs)
+		if n < len(pcs) {
+			c := make(Callers, n)
+			copy(c, pcs[:n])
+			return c
 		}
 
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+}
+
+// Resolve the program counters into frames (without source context).
+// Inlined calls are expanded into their own frames.
+func (c Callers) Stack() Stack {
+	if len(c) == 0 {
+		return nil
+	}
+
+	var (
+		stack  = make(Stack, 0, len(c))
+		frames = runtime.CallersFrames([]uintptr(c))
+	)
+
+	for {
+		f, more := frames.Next()
+
 		frame := StackFrame{
-			PC:       pc,
-			Filepath: file,
-			Line:     line,
-			InApp:    true,
+			PC:       f.PC,
+			Filepath: f.File,
+			Line:     f.Line,
+			Inlined:  f.Func == nil && f.Function != "",
 		}
 
-		function(pc, &frame)
+		function(f.Function, &frame)
 		filename(&frame)
+		frame.InApp = Rules.in_app(&frame)
+
+		stack = append(stack, frame)
+
+		if !more {
+			break
+		}
+	}
+
+	return stack
+}
+
+// Capture the stack of the calling goroutine including source context. The
+// first frame is CaptureStack itself. Prefer CaptureCallers on hot paths.
+func CaptureStack() Stack {
+	return CaptureCallers(0).Stack().WithContext()
+}
+
+// A copy of the stack with the source context of each frame loaded (from
+// the source cache).
+func (s Stack) WithContext() Stack {
+	if len(s) == 0 {
+		return s
+	}
+
+	o := make(Stack, len(s))
+	copy(o, s)
 
-		// load file
-		if file != lastFile {
-			data, err := ioutil.ReadFile(file)
-			if err == nil {
-				lines = bytes.Split(data, []byte{'\n'})
-				lastFile = file
-			} else {
-				lastFile = file
-				lines = nil
-			}
+	for i := range o {
+		frame := &o[i]
+
+		if frame.HasContext {
+			continue
 		}
 
-		if lines != nil {
-			line-- // in stack trace, lines are 1-indexed but our array is 0-indexed
-			frame.HasContext = true
-			context_line(lines, line, &frame)
-			pre_context(lines, line, &frame)
-			post_context(lines, line, &frame)
+		lines := source_files.lines(frame.Filepath)
+		if lines == nil {
+			continue
 		}
 
-		stack = append(stack, frame)
+		line := frame.Line - 1 // in stack trace, lines are 1-indexed but our array is 0-indexed
+		frame.HasContext = true
+		context_line(lines, line, frame)
+		pre_context(lines, line, frame)
+		post_context(lines, line, frame)
 	}
 
-	return Stack(stack)
+	return o
 }
 
 func pre_context(lines [][]byte, n int, frame *StackFrame) {
@@ -186,22 +303,3 @@ func source(lines [][]byte, n int) []byte {
 	}
 	return lines[n]
 }
-
-func function(pc uintptr, frame *StackFrame) {
-	f := runtime.FuncForPC(pc)
-	if f == nil {
-		return
-	}
-
-	fname := f.Name()
-	slash_idx := strings.LastIndex(fname, "/")
-	dot_idx := strings.Index(fname[slash_idx+1:], ".")
-	pkgpath, name := fname[:slash_idx+1+dot_idx], fname[slash_idx+dot_idx+2:]
-
-	frame.Package = pkgpath
-	frame.Function = name
-}
-
-func filename(frame *StackFrame) {
-	frame.Filename = path.Join(frame.Package, path.Base(frame.Filepath))
-}
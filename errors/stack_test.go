@@ -0,0 +1,25 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaptureCallers(t *testing.T) {
+	stack := CaptureCallers(0).Stack()
+	if len(stack) == 0 || stack[0].Function != "TestCaptureCallers" {
+		t.Fatalf("expected the first frame to be the caller, got %+v", stack)
+	}
+	if stack[0].HasContext {
+		t.Error("expected the stack to be resolved without source context")
+	}
+
+	stack = stack.WithContext()
+	if !stack[0].HasContext || !strings.Contains(stack[0].ContextLine, "CaptureCallers(0)") {
+		t.Errorf("expected the source context to be loaded, got %q", stack[0].ContextLine)
+	}
+
+	if stack := CaptureStack(); stack[0].Function != "CaptureStack" || stack[1].Function != "TestCaptureCallers" {
+		t.Errorf("expected CaptureStack to include itself, got %s %s", stack[0].Function, stack[1].Function)
+	}
+}
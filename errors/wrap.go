@@ -0,0 +1,41 @@
+package errors
+
+import (
+	std_errors "errors"
+)
+
+// Reports whether any error in the chain of err matches target. The chain
+// includes the errors wrapped by Annotate and all errors in (nested) Lists.
+func Is(err, target error) bool {
+	return std_errors.Is(err, target)
+}
+
+// Find the first error in the chain of err that matches target, and if so,
+// set target to that error value and return true.
+func As(err error, target interface{}) bool {
+	return std_errors.As(err, target)
+}
+
+// The root cause of err: the innermost error wrapped by Annotate. Lists
+// holding a single error are unwrapped; for Lists with multiple errors the
+// List itself is the cause. Errors from other packages are not unwrapped.
+func Cause(err error) error {
+	for {
+		switch e := err.(type) {
+		case *Error:
+			if e == nil || e.err == nil {
+				return err
+			}
+			err = e.err
+
+		case List:
+			if len(e) != 1 {
+				return err
+			}
+			err = e[0]
+
+		default:
+			return err
+		}
+	}
+}
@@ -34,6 +34,16 @@ type Logger interface {
 	Sub(level Level, namespace string) Logger
 }
 
+// A Logger which supports context fields
+type FieldLogger interface {
+	Logger
+
+	// A logger which appends the fields (as key=value) to each message
+	WithFields(fields map[string]interface{}) Logger
+	// A logger with the context fields of err
+	WithError(err error) Logger
+}
+
 var DefaultLogger = New(nil, INFO, "")
 
 func SetLevel(lvl Level) {
@@ -95,3 +105,20 @@ func Namespace() string {
 func Sub(level Level, namespace string) Logger {
 	return DefaultLogger.Sub(level, namespace)
 }
+
+// The DefaultLogger with fields (when it is a FieldLogger)
+func WithFields(fields map[string]interface{}) Logger {
+	if l, ok := DefaultLogger.(FieldLogger); ok {
+		return l.WithFields(fields)
+	}
+	return DefaultLogger
+}
+
+// The DefaultLogger with the context fields of err (when it is a
+// FieldLogger)
+func WithError(err error) Logger {
+	if l, ok := DefaultLogger.(FieldLogger); ok {
+		return l.WithError(err)
+	}
+	return DefaultLogger
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
+
+	"github.com/fd/go-util/errors"
 )
 
 func New(w io.Writer, l Level, namespace string) Logger {
@@ -11,13 +15,14 @@ func New(w io.Writer, l Level, namespace string) Logger {
 		w = os.Stdout
 	}
 
-	return &logger{w, namespace, l}
+	return &logger{w: w, namespace: namespace, level: l}
 }
 
 type logger struct {
 	w         io.Writer
 	namespace string
 	level     Level
+	fields    map[string]interface{}
 }
 
 func (l *logger) SetLevel(lvl Level) {
@@ -41,7 +46,23 @@ func (l *logger) Sub(level Level, namespace string) Logger {
 		level = l.level
 	}
 
-	return New(l.w, level, namespace)
+	return &logger{w: l.w, namespace: namespace, level: level, fields: l.fields}
+}
+
+func (l *logger) WithFields(fields map[string]interface{}) Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
+	return &logger{w: l.w, namespace: l.namespace, level: l.level, fields: merged}
+}
+
+func (l *logger) WithError(err error) Logger {
+	return l.WithFields(errors.RedactedFields(err))
 }
 
 func (l *logger) Debug(args ...interface{}) {
@@ -94,18 +115,46 @@ func (l *logger) Fatalf(format string, args ...interface{}) {
 
 func (l *logger) emit(level Level, args ...interface{}) {
 	if level >= l.level {
-		entry := l.format(level, fmt.Sprint(args...))
+		entry := l.format(level, fmt.Sprint(args...)+l.format_fields())
 		emit_ch <- emit{l.w, []byte(entry)}
 	}
 }
 
 func (l *logger) emitf(level Level, format string, args ...interface{}) {
 	if level >= l.level {
-		entry := l.format(level, fmt.Sprintf(format, args...))
+		entry := l.format(level, fmt.Sprintf(format, args...)+l.format_fields())
 		emit_ch <- emit{l.w, []byte(entry)}
 	}
 }
 
+func (l *logger) format_fields() string {
+	if len(l.fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if l.fields[key] == nil {
+			parts[i] = key
+			continue
+		}
+
+		value := fmt.Sprint(l.fields[key])
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		parts[i] = key + "=" + value
+	}
+
+	return " " + strings.Join(parts, " ")
+}
+
 var emit_ch = make(chan emit, 100)
 
 type emit struct {